@@ -1,19 +1,28 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 
+	"gitlab.com/innoserver/pkg/event"
 	"gitlab.com/innoserver/pkg/handler"
+	"gitlab.com/innoserver/pkg/live"
 	"gitlab.com/innoserver/pkg/model"
+	"gitlab.com/innoserver/pkg/notify"
+	"gitlab.com/innoserver/pkg/push"
 	"gitlab.com/innoserver/pkg/repository"
+	"gitlab.com/innoserver/pkg/session"
+	"gitlab.com/innoserver/pkg/sweeper"
+	"gitlab.com/innoserver/pkg/webhook"
 )
 
 func main() {
@@ -51,6 +60,11 @@ func main() {
 	}
 	log.Infoln("configuration successfully")
 	log.Infoln("server started")
+	if config.UploadPath != "" {
+		if err := os.MkdirAll("."+config.UploadPath, 0755); err != nil {
+			log.Errorln("error creating the upload directory: ", err)
+		}
+	}
 
 	userRepository, err := repository.NewUserRepository(db)
 	if err != nil {
@@ -64,6 +78,50 @@ func main() {
 	if err != nil {
 		log.Errorln("error creating the group repository:", err)
 	}
+	uploadRepository, err := repository.NewUploadRepository(db)
+	if err != nil {
+		log.Errorln("error creating the upload repository:", err)
+	}
+	blobRepository, err := repository.NewBlobRepository(db)
+	if err != nil {
+		log.Errorln("error creating the blob repository:", err)
+	}
+	sessionRepository, err := repository.NewSessionRepository(db)
+	if err != nil {
+		log.Errorln("error creating the session repository:", err)
+	}
+	votingRepository, err := repository.NewVotingRepository(db)
+	if err != nil {
+		log.Errorln("error creating the voting repository:", err)
+	}
+	commentRepository, err := repository.NewCommentRepository(db)
+	if err != nil {
+		log.Errorln("error creating the comment repository:", err)
+	}
+	reactionRepository, err := repository.NewReactionRepository(db)
+	if err != nil {
+		log.Errorln("error creating the reaction repository:", err)
+	}
+	collectionRepository, err := repository.NewCollectionRepository(db)
+	if err != nil {
+		log.Errorln("error creating the collection repository:", err)
+	}
+	mentionRepository, err := repository.NewMentionRepository(db)
+	if err != nil {
+		log.Errorln("error creating the mention repository:", err)
+	}
+	notificationRepository, err := repository.NewNotificationRepository(db)
+	if err != nil {
+		log.Errorln("error creating the notification repository:", err)
+	}
+	deviceRepository, err := repository.NewDeviceRepository(db)
+	if err != nil {
+		log.Errorln("error creating the device repository:", err)
+	}
+	webhookRepository, err := repository.NewWebhookRepository(db)
+	if err != nil {
+		log.Errorln("error creating the webhook repository:", err)
+	}
 
 	defer func() {
 		log.Println("closing database statements")
@@ -76,8 +134,98 @@ func main() {
 		if err = groupRepository.Close(); err != nil {
 			log.Errorln("group repository:", err.Error())
 		}
+		if err = uploadRepository.Close(); err != nil {
+			log.Errorln("upload repository:", err.Error())
+		}
+		if err = blobRepository.Close(); err != nil {
+			log.Errorln("blob repository:", err.Error())
+		}
+		if err = sessionRepository.Close(); err != nil {
+			log.Errorln("session repository:", err.Error())
+		}
+		if err = votingRepository.Close(); err != nil {
+			log.Errorln("voting repository:", err.Error())
+		}
+		if err = commentRepository.Close(); err != nil {
+			log.Errorln("comment repository:", err.Error())
+		}
+		if err = reactionRepository.Close(); err != nil {
+			log.Errorln("reaction repository:", err.Error())
+		}
+		if err = collectionRepository.Close(); err != nil {
+			log.Errorln("collection repository:", err.Error())
+		}
+		if err = mentionRepository.Close(); err != nil {
+			log.Errorln("mention repository:", err.Error())
+		}
+		if err = notificationRepository.Close(); err != nil {
+			log.Errorln("notification repository:", err.Error())
+		}
+		if err = deviceRepository.Close(); err != nil {
+			log.Errorln("device repository:", err.Error())
+		}
+		if err = webhookRepository.Close(); err != nil {
+			log.Errorln("webhook repository:", err.Error())
+		}
 	}()
 
+	if config.SweepInterval > 0 {
+		sweep := sweeper.New(blobRepository, uploadRepository, config, log)
+		go sweep.Run(context.Background(), time.Duration(config.SweepInterval)*time.Second)
+	}
+
+	if config.SessionTick > 0 {
+		clock := session.NewClock(sessionRepository, log)
+		go clock.Run(context.Background(), time.Duration(config.SessionTick)*time.Second)
+	}
+
+	bus := event.NewBus()
+	notifier := notify.NewNotifier(notificationRepository, log)
+	bus.Subscribe(notifier.Notify)
+	go notifier.Run(context.Background())
+
+	hub := live.NewHub(log)
+	bus.Subscribe(hub.Publish)
+	eventLog := live.NewLog(config.EventLogSize)
+	bus.Subscribe(eventLog.Append)
+
+	providers := map[string]push.Provider{}
+	if config.PushFake {
+		fake := push.NewFake(log)
+		providers[model.PlatformFcm] = fake
+		providers[model.PlatformApns] = fake
+	}
+	if config.FcmCredentialsFile != "" {
+		fcm, err := push.NewFCM(config.FcmCredentialsFile, config.FcmEndpoint)
+		if err != nil {
+			log.Errorln("error creating the fcm provider:", err)
+		} else {
+			providers[model.PlatformFcm] = fcm
+		}
+	}
+	if config.ApnsKeyFile != "" {
+		apns, err := push.NewAPNs(config.ApnsKeyFile, config.ApnsKeyID, config.ApnsTeamID,
+			config.ApnsTopic, config.ApnsEndpoint)
+		if err != nil {
+			log.Errorln("error creating the apns provider:", err)
+		} else {
+			providers[model.PlatformApns] = apns
+		}
+	}
+	if len(providers) > 0 {
+		dispatcher := push.NewDispatcher(deviceRepository, notificationRepository, providers, config.PushRetries, log)
+		bus.Subscribe(dispatcher.Notify)
+		go dispatcher.Run(context.Background())
+	}
+
+	deliverer := webhook.NewDeliverer(webhookRepository, config.WebhookMaxAttempts,
+		config.WebhookAllowedHosts, log)
+	bus.Subscribe(deliverer.Enqueue)
+	go deliverer.Collect(context.Background())
+	if config.WebhookInterval > 0 {
+		go deliverer.Run(context.Background(), time.Duration(config.WebhookInterval)*time.Second)
+	}
+
 	logger := [2]*logrus.Logger{log, rlog}
 	srvStr := config.ServerAddress + ":" + config.ServerPort
 	srv := &http.Server{
@@ -88,6 +236,21 @@ func main() {
 			userRepository,
 			postRepository,
 			groupRepository,
+			uploadRepository,
+			blobRepository,
+			sessionRepository,
+			votingRepository,
+			commentRepository,
+			reactionRepository,
+			collectionRepository,
+			mentionRepository,
+			notificationRepository,
+			deviceRepository,
+			webhookRepository,
+			bus,
+			hub,
+			eventLog,
+			deliverer,
 			config,
 			logger,
 		),
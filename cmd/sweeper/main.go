@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+	"gitlab.com/innoserver/pkg/repository"
+	"gitlab.com/innoserver/pkg/sweeper"
+)
+
+func main() {
+	config := &model.Config{}
+	configPtr := flag.String("config", "./init/config.json", "path to the json config file")
+	dryRun := flag.Bool("dry-run", true, "only report orphaned files, use -dry-run=false to remove them")
+	flag.Parse()
+	if configJson, err := ioutil.ReadFile(*configPtr); err == nil {
+		if err = json.Unmarshal(configJson, config); err != nil {
+			logrus.Println("error parsing config file", *configPtr)
+		}
+	}
+	connectionStr := config.DatabaseUser + ":" + config.DatabasePassword + "@tcp(" +
+		config.DatabaseAddress + ":" + config.DatabasePort + ")/" + config.Database +
+		"?parseTime=true"
+	db, err := sqlx.Open("mysql", connectionStr)
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+	defer db.Close()
+	log := logrus.New()
+	log.SetOutput(os.Stderr)
+
+	blobRepository, err := repository.NewBlobRepository(db)
+	if err != nil {
+		log.Fatalln("error creating the blob repository:", err)
+	}
+	defer blobRepository.Close()
+	uploadRepository, err := repository.NewUploadRepository(db)
+	if err != nil {
+		log.Fatalln("error creating the upload repository:", err)
+	}
+	defer uploadRepository.Close()
+
+	sweep := sweeper.New(blobRepository, uploadRepository, config, log)
+	sweep.DryRun = *dryRun
+	report, err := sweep.Sweep(context.Background())
+	if err != nil {
+		log.Errorln("sweeping media files failed:", err)
+	}
+	out, _ := json.MarshalIndent(report, "", "  ")
+	os.Stdout.Write(append(out, '\n'))
+}
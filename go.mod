@@ -8,6 +8,7 @@ require (
 	github.com/go-sql-driver/mysql v1.4.0
 	github.com/google/uuid v1.1.1
 	github.com/gorilla/mux v1.7.3
+	github.com/gorilla/websocket v1.5.0
 	github.com/ido50/sqlz v0.0.0-20191128141335-dab5be663cd1
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/sirupsen/logrus v1.4.2
@@ -1,5 +1,23 @@
 DROP VIEW IF EXISTS detailed_posts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS devices;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS mentions;
+DROP TABLE IF EXISTS collection_posts;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS reactions;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS votes;
+DROP TABLE IF EXISTS votings;
+DROP TABLE IF EXISTS session_contributions;
+DROP TABLE IF EXISTS session_participants;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS uploads;
+DROP TABLE IF EXISTS blobs;
 DROP TABLE IF EXISTS options;
+DROP TABLE IF EXISTS post_media;
 DROP TABLE IF EXISTS group_user;
 DROP TABLE IF EXISTS posts;
 DROP TABLE IF EXISTS groups;
@@ -33,14 +51,20 @@ CREATE TABLE posts (
   method int NOT NULL,
   type tinyint(1) NOT NULL,
   group_id int DEFAULT NULL,
+  size bigint NOT NULL DEFAULT 0,
+  content mediumtext NOT NULL,
+  position int NOT NULL DEFAULT 0,
+  prompt varchar(1) NOT NULL DEFAULT '',
   FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
   FOREIGN KEY(group_id) REFERENCES groups(id) ON DELETE CASCADE,
   FOREIGN KEY(parent_id) REFERENCES posts(id) ON DELETE CASCADE
 );
 
 CREATE TABLE group_user (
+  id int PRIMARY KEY AUTO_INCREMENT,
   group_id int NOT NULL,
   user_id int NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   FOREIGN KEY(group_id) REFERENCES groups(id) ON DELETE CASCADE,
   FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 );
@@ -49,9 +73,237 @@ CREATE TABLE options (
   post_uid varchar(255) NOT NULL,
   opt_key varchar(255) NOT NULL,
   opt_value varchar(255) NOT NULL,
+  PRIMARY KEY(post_uid, opt_key),
   FOREIGN KEY(post_uid) REFERENCES posts(unique_id) ON DELETE CASCADE
 );
 
+CREATE TABLE post_media (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  post_id int NOT NULL,
+  position int NOT NULL,
+  path varchar(255) NOT NULL,
+  type tinyint(1) NOT NULL,
+  size bigint NOT NULL DEFAULT 0,
+  UNIQUE(post_id, position),
+  FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE uploads (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  unique_id varchar(255) NOT NULL UNIQUE,
+  user_id int NOT NULL,
+  group_id int DEFAULT NULL,
+  parent_id int DEFAULT NULL,
+  title varchar(255) NOT NULL,
+  method int NOT NULL,
+  type tinyint(1) NOT NULL,
+  position int NOT NULL DEFAULT 0,
+  prompt varchar(1) NOT NULL DEFAULT '',
+  options text NOT NULL,
+  extension varchar(32) NOT NULL,
+  size bigint NOT NULL,
+  received bigint NOT NULL DEFAULT 0,
+  completing tinyint(1) NOT NULL DEFAULT 0,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(group_id) REFERENCES groups(id) ON DELETE CASCADE,
+  FOREIGN KEY(parent_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE blobs (
+  hash varchar(64) NOT NULL,
+  path varchar(255) NOT NULL,
+  type tinyint(1) NOT NULL,
+  size bigint NOT NULL,
+  ref_count int NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY(type, path)
+);
+
+CREATE TABLE sessions (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  unique_id varchar(255) NOT NULL UNIQUE,
+  post_id int NOT NULL,
+  facilitator_id int NOT NULL,
+  group_id int DEFAULT NULL,
+  method int NOT NULL,
+  round_duration int NOT NULL,
+  rounds int NOT NULL DEFAULT 0,
+  ideas_per_round int NOT NULL DEFAULT 1,
+  current_round int NOT NULL DEFAULT 0,
+  round_deadline TIMESTAMP NULL DEFAULT NULL,
+  state tinyint NOT NULL DEFAULT 0,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  FOREIGN KEY(facilitator_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(group_id) REFERENCES groups(id) ON DELETE CASCADE
+);
+
+CREATE TABLE session_participants (
+  session_id int NOT NULL,
+  user_id int NOT NULL,
+  seat int NOT NULL,
+  PRIMARY KEY(session_id, user_id),
+  UNIQUE(session_id, seat),
+  FOREIGN KEY(session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE session_contributions (
+  session_id int NOT NULL,
+  post_id int NOT NULL,
+  user_id int NOT NULL,
+  round int NOT NULL,
+  sheet int NOT NULL,
+  idea int NOT NULL,
+  UNIQUE(session_id, round, sheet, idea),
+  FOREIGN KEY(session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+  FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE votings (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  unique_id varchar(255) NOT NULL UNIQUE,
+  post_id int DEFAULT NULL,
+  group_id int DEFAULT NULL,
+  facilitator_id int NOT NULL,
+  mode tinyint NOT NULL,
+  votes_per_user int NOT NULL,
+  state tinyint NOT NULL DEFAULT 0,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  FOREIGN KEY(group_id) REFERENCES groups(id) ON DELETE CASCADE,
+  FOREIGN KEY(facilitator_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE votes (
+  voting_id int NOT NULL,
+  user_id int NOT NULL,
+  post_id int NOT NULL,
+  value int NOT NULL,
+  PRIMARY KEY(voting_id, user_id, post_id),
+  FOREIGN KEY(voting_id) REFERENCES votings(id) ON DELETE CASCADE,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE comments (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  unique_id varchar(255) NOT NULL UNIQUE,
+  post_id int NOT NULL,
+  user_id int NOT NULL,
+  parent_id int DEFAULT NULL,
+  content text NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(parent_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE TABLE reactions (
+  post_id int NOT NULL,
+  user_id int NOT NULL,
+  kind varchar(32) NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY(post_id, user_id, kind),
+  FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE collections (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  unique_id varchar(255) NOT NULL UNIQUE,
+  user_id int NOT NULL,
+  name varchar(255) NOT NULL,
+  is_default boolean NOT NULL DEFAULT false,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE(user_id, name),
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE collection_posts (
+  collection_id int NOT NULL,
+  post_id int NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY(collection_id, post_id),
+  FOREIGN KEY(collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+  FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE mentions (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  user_id int NOT NULL,
+  author_id int NOT NULL,
+  post_id int NOT NULL,
+  comment_id int,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(author_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  FOREIGN KEY(comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE TABLE notifications (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  unique_id varchar(255) NOT NULL UNIQUE,
+  user_id int NOT NULL,
+  type varchar(64) NOT NULL,
+  actor_id int NOT NULL,
+  post_uid varchar(255) NOT NULL DEFAULT "",
+  group_uid varchar(255) NOT NULL DEFAULT "",
+  is_read boolean NOT NULL DEFAULT false,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(actor_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE notification_preferences (
+  user_id int NOT NULL,
+  type varchar(64) NOT NULL,
+  enabled boolean NOT NULL,
+  PRIMARY KEY(user_id, type),
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE devices (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  user_id int NOT NULL,
+  platform varchar(16) NOT NULL,
+  token varchar(255) NOT NULL UNIQUE,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE webhooks (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  unique_id varchar(255) NOT NULL UNIQUE,
+  group_id int NOT NULL,
+  url varchar(2048) NOT NULL,
+  secret varchar(255) NOT NULL,
+  events text NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY(group_id) REFERENCES groups(id) ON DELETE CASCADE
+);
+
+CREATE TABLE webhook_deliveries (
+  id int PRIMARY KEY AUTO_INCREMENT,
+  unique_id varchar(255) NOT NULL UNIQUE,
+  webhook_id int NOT NULL,
+  event_type varchar(64) NOT NULL,
+  payload text NOT NULL,
+  state int NOT NULL DEFAULT 0,
+  attempts int NOT NULL DEFAULT 0,
+  next_attempt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_status int NOT NULL DEFAULT 0,
+  last_error varchar(1024) NOT NULL DEFAULT "",
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  delivered_at TIMESTAMP NULL,
+  FOREIGN KEY(webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE,
+  INDEX(state, next_attempt)
+);
+
 CREATE VIEW detailed_posts AS
   SELECT a.*, COALESCE(b.unique_id, "") AS parent_uid,
          COALESCE(d.unique_id, "") AS group_uid,
@@ -0,0 +1,49 @@
+// Package event distributes the events emitted by the handlers to the parts
+// of the server reacting on them, like the notifications of the users.
+package event
+
+import (
+	"sync"
+	"time"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// Bus passes every published event to all subscribers
+type Bus struct {
+	lock        sync.RWMutex
+	next        int
+	subscribers map[int]func(*model.Event)
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: map[int]func(*model.Event){}}
+}
+
+// Subscribe registers f for all events published from now on and returns a
+// function cancelling the subscription. Subscribers are called synchronously
+// by the publishing request and must not block.
+func (b *Bus) Subscribe(f func(*model.Event)) func() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	id := b.next
+	b.next++
+	b.subscribers[id] = f
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// Publish passes the event to all subscribers
+func (b *Bus) Publish(e *model.Event) {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for _, f := range b.subscribers {
+		f(e)
+	}
+}
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// InitUpload swagger:route POST /post/upload/init post initUpload
+//
+// Starts a resumable upload for large files. The returned unique id is used
+// to transfer the file in chunks and to complete the upload afterwards.
+//
+// consumes:
+//     multipart/form-data
+//     application/x-www-form-urlencoded
+//
+// responses:
+//     200: uidResponse
+//     400: description: bad request
+//     500: description: internal server error
+func (s *Handler) InitUpload(w http.ResponseWriter, r *http.Request) (error, int) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	post := &model.Post{}
+	err = initNewPostUpload(post, s, r)
+	if err != nil {
+		return logResponse(w, "upload initialisation failed", s.rlog.WithError(err), http.StatusBadRequest)
+	}
+	if !isFilePostType(post.Type) {
+		return logResponse(w, "wrong type for post",
+			s.rlog.WithFields(logrus.Fields{
+				"type": post.Type,
+			}), http.StatusBadRequest)
+	}
+	size, err := strconv.ParseInt(r.FormValue("size"), 10, 64)
+	if err != nil || size <= 0 || size > determineMaxPostSize(post, s) {
+		return logResponse(w, "missing or invalid upload size",
+			s.rlog.WithField("size", r.FormValue("size")), http.StatusBadRequest)
+	}
+	if err, status := s.checkQuota(w, r, user, post, size); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	if err, status := s.checkPostStructure(w, r, post); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	contentType := r.FormValue("content_type")
+	if contentType == "" {
+		return ErrMissingParam(w, "content_type", s.rlog)
+	}
+	options, err := json.Marshal(post.Options)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	upload := &model.Upload{
+		UserID:    user.ID,
+		GroupID:   post.GroupID,
+		ParentID:  post.ParentID,
+		Title:     post.Title,
+		Method:    post.Method,
+		Type:      post.Type,
+		Position:  post.Position,
+		Prompt:    post.Prompt,
+		Options:   string(options),
+		Extension: extensionOfContentType(contentType),
+		Size:      size,
+	}
+	upload.UniqueID, err = generateUid(s.uploadRepo, r)
+	if err != nil || upload.UniqueID == "" {
+		return err, http.StatusInternalServerError
+	}
+	f, err := os.OpenFile(partialUploadPath(s, upload), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	f.Close()
+	if err := s.uploadRepo.Persist(r.Context(), upload); err != nil {
+		os.Remove(partialUploadPath(s, upload))
+		return err, http.StatusInternalServerError
+	}
+	s.log.WithFields(logrus.Fields{
+		"upload": upload.UniqueID, "size": upload.Size, "user": user.Name,
+	}).Infoln("resumable upload initialised")
+	return WriteJsonResp(w, &model.UidResponse{UniqueID: upload.UniqueID})
+}
+
+// UploadStatus swagger:route GET /post/upload/status post uploadStatus
+//
+// Returns the current offset of a resumable upload, the next chunk has to
+// start at this offset
+//
+// responses:
+//     200: Upload
+//     400: description: bad request
+//     404: description: upload not found
+//     500: description: internal server error
+func (s *Handler) UploadStatus(w http.ResponseWriter, r *http.Request) (error, int) {
+	upload, err, status := s.currentUpload(w, r)
+	if upload == nil {
+		return err, status
+	}
+	return WriteJsonResp(w, upload)
+}
+
+// UploadChunk swagger:route POST /post/upload/chunk post uploadChunk
+//
+// Appends the request body to a resumable upload. If the offset doesn't match
+// the already received bytes, the chunk is rejected and the client has to
+// resume from the offset returned by the status route.
+//
+// consumes:
+//     application/octet-stream
+//
+// responses:
+//     200: Upload
+//     400: description: bad request
+//     404: description: upload not found
+//     409: description: offset mismatch or upload is being completed
+//     500: description: internal server error
+func (s *Handler) UploadChunk(w http.ResponseWriter, r *http.Request) (error, int) {
+	upload, err, status := s.currentUpload(w, r)
+	if upload == nil {
+		return err, status
+	}
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		return ErrMissingParam(w, "offset", s.rlog)
+	}
+	if upload.Completing {
+		return logResponse(w, "upload is being completed",
+			s.rlog.WithField("upload", upload.UniqueID), http.StatusConflict)
+	}
+	if offset != upload.Received {
+		return logResponse(w, "chunk offset doesn't match the received bytes",
+			s.rlog.WithFields(logrus.Fields{
+				"upload":   upload.UniqueID,
+				"offset":   offset,
+				"received": upload.Received,
+			}), http.StatusConflict)
+	}
+	limit := upload.Size - upload.Received
+	if s.config.MaxChunkSize > 0 && s.config.MaxChunkSize < limit {
+		limit = s.config.MaxChunkSize
+	}
+	f, err := os.OpenFile(partialUploadPath(s, upload), os.O_WRONLY, 0666)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	written, err := io.Copy(f, io.LimitReader(r.Body, limit+1))
+	if err == nil && written > limit {
+		err = errors.New("chunk exceeds the allowed size")
+	}
+	if err != nil {
+		f.Truncate(offset)
+		return logResponse(w, "writing chunk failed",
+			s.rlog.WithField("upload", upload.UniqueID).WithError(err), http.StatusBadRequest)
+	}
+	if err := f.Truncate(offset + written); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	upload.Received = offset + written
+	if err := s.uploadRepo.UpdateReceived(r.Context(), upload); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return WriteJsonResp(w, upload)
+}
+
+// CompleteUpload swagger:route POST /post/upload/complete post completeUpload
+//
+// Finishes a resumable upload after all chunks were received and creates
+// the post. While an upload is being completed, further completions and
+// chunks are rejected. If creating the post fails, the upload is kept and
+// the completion can be retried.
+//
+// responses:
+//     200: uidResponse
+//     400: description: bad request
+//     404: description: upload not found
+//     409: description: upload is already being completed
+//     500: description: internal server error
+func (s *Handler) CompleteUpload(w http.ResponseWriter, r *http.Request) (error, int) {
+	upload, err, status := s.currentUpload(w, r)
+	if upload == nil {
+		return err, status
+	}
+	if upload.Received != upload.Size {
+		return logResponse(w, "upload is not complete yet",
+			s.rlog.WithFields(logrus.Fields{
+				"upload":   upload.UniqueID,
+				"size":     upload.Size,
+				"received": upload.Received,
+			}), http.StatusBadRequest)
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	post := &model.Post{
+		Title:    upload.Title,
+		ParentID: upload.ParentID,
+		GroupID:  upload.GroupID,
+		Method:   upload.Method,
+		Type:     upload.Type,
+		Position: upload.Position,
+		Prompt:   upload.Prompt,
+		Size:     upload.Size,
+	}
+	if err := json.Unmarshal([]byte(upload.Options), &post.Options); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err, status := s.checkQuota(w, r, user, post, post.Size); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	claimed, err := s.uploadRepo.Claim(r.Context(), upload)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if !claimed {
+		return logResponse(w, "upload is already being completed",
+			s.rlog.WithField("upload", upload.UniqueID), http.StatusConflict)
+	}
+	partial, err := os.Open(partialUploadPath(s, upload))
+	if err != nil {
+		s.releaseUpload(r, upload)
+		return err, http.StatusInternalServerError
+	}
+	path, err := s.storeFile(r.Context(), partial, upload.Extension, upload.Type)
+	partial.Close()
+	if err != nil {
+		s.releaseUpload(r, upload)
+		return err, http.StatusInternalServerError
+	}
+	post.Path = path
+	post.Media = []*model.PostMedia{{Path: path, Type: upload.Type, Size: upload.Size}}
+	if err, status := s.persistPost(w, r, post, user); err != nil || status != http.StatusOK {
+		s.releaseUpload(r, upload)
+		return err, status
+	}
+	s.removeUpload(r, upload)
+	return nil, http.StatusOK
+}
+
+// currentUpload fetches the upload of the request and ensures it belongs to
+// the current user. If the returned upload is nil, the error and status
+// have to be returned by the calling handler.
+func (s *Handler) currentUpload(w http.ResponseWriter, r *http.Request) (*model.Upload, error, int) {
+	uploadUid := r.URL.Query().Get("upload_uid")
+	if uploadUid == "" {
+		err, status := ErrMissingParam(w, "upload_uid", s.rlog)
+		return nil, err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	upload, err := s.uploadRepo.GetByUid(r.Context(), uploadUid)
+	if err == sql.ErrNoRows || (err == nil && upload.UserID != user.ID) {
+		err, status := logResponse(w, "upload not found",
+			s.rlog.WithField("upload_uid", uploadUid), http.StatusNotFound)
+		return nil, err, status
+	}
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	return upload, nil, http.StatusOK
+}
+
+// releaseUpload takes back the claim of a failed completion
+func (s *Handler) releaseUpload(r *http.Request, upload *model.Upload) {
+	if err := s.uploadRepo.Release(r.Context(), upload); err != nil {
+		s.log.WithField("upload", upload.UniqueID).WithError(err).Errorln("releasing upload failed")
+	}
+}
+
+// removeUpload discards a completed upload together with its partial file
+func (s *Handler) removeUpload(r *http.Request, upload *model.Upload) {
+	if err := s.uploadRepo.Remove(r.Context(), upload); err != nil {
+		s.log.WithField("upload", upload.UniqueID).WithError(err).Errorln("removing upload failed")
+	}
+	if err := os.Remove(partialUploadPath(s, upload)); err != nil {
+		s.log.WithField("upload", upload.UniqueID).WithError(err).Errorln("removing partial file failed")
+	}
+}
+
+func partialUploadPath(s *Handler, upload *model.Upload) string {
+	return "." + s.config.UploadPath + upload.UniqueID
+}
@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// CreateCollection swagger:route POST /collection/create collection createCollection
+//
+// Creates a named collection for the current user
+//
+// responses:
+//     200: uidResponse
+//     400: description: bad request
+//     409: description: collection with this name exists
+//     500: description: internal server error
+func (s *Handler) CreateCollection(w http.ResponseWriter, r *http.Request) (error, int) {
+	details := &model.CreateCollectionRequestBody{}
+	if err := json.NewDecoder(r.Body).Decode(&details.Info); err != nil {
+		return logResponse(w, "error encoding json",
+			s.rlog.WithFields(logrus.Fields{}).WithError(err), http.StatusBadRequest)
+	}
+	if details.Info.Name == "" {
+		return ErrMissingParam(w, "name", s.rlog)
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	collections, err := s.collectionRepo.SelectByUser(r.Context(), user)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	for _, collection := range collections {
+		if collection.Name == details.Info.Name {
+			return logResponse(w, "collection exists",
+				s.rlog.WithField("name", details.Info.Name), http.StatusConflict)
+		}
+	}
+	if details.Info.Name == model.DefaultCollection {
+		return logResponse(w, "name is reserved for the default collection",
+			s.rlog.WithField("name", details.Info.Name), http.StatusConflict)
+	}
+	collection := &model.Collection{UserID: user.ID, Name: details.Info.Name}
+	collection.UniqueID, err = generateUid(s.collectionRepo, r)
+	if err != nil || collection.UniqueID == "" {
+		return err, http.StatusInternalServerError
+	}
+	if err := s.collectionRepo.Persist(r.Context(), collection); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return WriteJsonResp(w, &model.UidResponse{UniqueID: collection.UniqueID})
+}
+
+// ListCollections swagger:route GET /collection/list collection listCollections
+//
+// Returns the collections of the current user without their posts
+//
+// responses:
+//     200: []Collection
+//     500: description: internal server error
+func (s *Handler) ListCollections(w http.ResponseWriter, r *http.Request) (error, int) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	collections, err := s.collectionRepo.SelectByUser(r.Context(), user)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return WriteJsonResp(w, collections)
+}
+
+// GetCollection swagger:route GET /collection/get collection getCollection
+//
+// Returns a collection with its posts. Posts of groups the user can no
+// longer access are left out.
+//
+// responses:
+//     200: Collection
+//     400: description: bad request
+//     404: description: collection not found
+//     500: description: internal server error
+func (s *Handler) GetCollection(w http.ResponseWriter, r *http.Request) (error, int) {
+	collection, err, status := s.currentCollection(w, r)
+	if collection == nil {
+		return err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err := s.appendCollectionPosts(r.Context(), user, collection); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return WriteJsonResp(w, collection)
+}
+
+// RemoveCollection swagger:route GET /collection/remove collection removeCollection
+//
+// Removes a named collection, the default collection can not be removed
+//
+// responses:
+//     200: description: successfully removed collection
+//     400: description: bad request
+//     404: description: collection not found
+//     500: description: internal server error
+func (s *Handler) RemoveCollection(w http.ResponseWriter, r *http.Request) (error, int) {
+	collection, err, status := s.currentCollection(w, r)
+	if collection == nil {
+		return err, status
+	}
+	if collection.Default {
+		return logResponse(w, "the default collection can not be removed",
+			s.rlog.WithField("collection_uid", collection.UniqueID), http.StatusBadRequest)
+	}
+	if err := s.collectionRepo.Remove(r.Context(), collection); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// CollectPost swagger:route GET /collection/add collection collectPost
+//
+// Adds a post to a collection of the current user. Without a collection the
+// post is saved to the default collection.
+//
+// responses:
+//     200: description: successfully added post
+//     400: description: bad request
+//     401: description: user is not in the group of the post
+//     404: description: collection or post not found
+//     500: description: internal server error
+func (s *Handler) CollectPost(w http.ResponseWriter, r *http.Request) (error, int) {
+	post, err, status := s.accessiblePost(w, r)
+	if post == nil {
+		return err, status
+	}
+	collection, err, status := s.targetCollection(w, r)
+	if collection == nil {
+		return err, status
+	}
+	if err := s.collectionRepo.AddPost(r.Context(), collection, post); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// UncollectPost swagger:route GET /collection/removepost collection uncollectPost
+//
+// Removes a post from a collection of the current user. Without a collection
+// the post is removed from the default collection.
+//
+// responses:
+//     200: description: successfully removed post
+//     400: description: bad request
+//     404: description: collection or post not found
+//     500: description: internal server error
+func (s *Handler) UncollectPost(w http.ResponseWriter, r *http.Request) (error, int) {
+	postUid := r.URL.Query().Get("post_uid")
+	if postUid == "" {
+		return ErrMissingParam(w, "post_uid", s.rlog)
+	}
+	// access to the post is not checked, users may always clean up their collections
+	post, err := s.postRepo.GetByUid(r.Context(), postUid)
+	if err == sql.ErrNoRows {
+		return logResponse(w, "post not found", s.rlog.WithField("post_uid", postUid), http.StatusNotFound)
+	}
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	collection, err, status := s.targetCollection(w, r)
+	if collection == nil {
+		return err, status
+	}
+	if err := s.collectionRepo.RemovePost(r.Context(), collection, post); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// currentCollection fetches the collection of the request, which has to be
+// owned by the current user. If the returned collection is nil, the error and
+// status have to be returned by the calling handler.
+func (s *Handler) currentCollection(w http.ResponseWriter, r *http.Request) (*model.Collection, error, int) {
+	collectionUid := r.URL.Query().Get("collection_uid")
+	if collectionUid == "" {
+		err, status := ErrMissingParam(w, "collection_uid", s.rlog)
+		return nil, err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	collection, err := s.collectionRepo.GetByUid(r.Context(), collectionUid)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err, http.StatusInternalServerError
+	}
+	// collections of other users are reported as missing to not reveal them
+	if err == sql.ErrNoRows || collection.UserID != user.ID {
+		err, status := logResponse(w, "collection not found",
+			s.rlog.WithField("collection_uid", collectionUid), http.StatusNotFound)
+		return nil, err, status
+	}
+	return collection, nil, http.StatusOK
+}
+
+// targetCollection returns the requested collection or the default collection
+// of the user, if none was requested
+func (s *Handler) targetCollection(w http.ResponseWriter, r *http.Request) (*model.Collection, error, int) {
+	if r.URL.Query().Get("collection_uid") != "" {
+		return s.currentCollection(w, r)
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	collection, err := s.defaultCollection(r, user)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	return collection, nil, http.StatusOK
+}
+
+// defaultCollection returns the default collection of the user and creates it
+// on first use
+func (s *Handler) defaultCollection(r *http.Request, user *model.User) (*model.Collection, error) {
+	collection, err := s.collectionRepo.GetDefault(r.Context(), user)
+	if err != sql.ErrNoRows {
+		return collection, err
+	}
+	collection = &model.Collection{UserID: user.ID, Name: model.DefaultCollection, Default: true}
+	collection.UniqueID, err = generateUid(s.collectionRepo, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.collectionRepo.Persist(r.Context(), collection); err != nil {
+		return nil, err
+	}
+	return s.collectionRepo.GetDefault(r.Context(), user)
+}
+
+// appendCollectionPosts loads the posts of the collections, dropping posts of
+// groups the user is not in anymore unless the group is public
+func (s *Handler) appendCollectionPosts(ctx context.Context, user *model.User,
+	collections ...*model.Collection) error {
+	groups, err := s.groupRepo.SelectByUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	accessible := make(map[int]bool, len(groups))
+	for _, group := range groups {
+		accessible[group.ID] = true
+	}
+	for _, collection := range collections {
+		posts, err := s.postRepo.SelectByCollection(ctx, collection)
+		if err != nil {
+			return err
+		}
+		collection.Posts = []*model.Post{}
+		for _, post := range posts {
+			if post.GroupID.Valid {
+				id := int(post.GroupID.Int32)
+				if _, ok := accessible[id]; !ok {
+					group, err := s.groupRepo.GetByID(ctx, id)
+					if err != nil {
+						return err
+					}
+					accessible[id] = group.Public
+				}
+				if !accessible[id] {
+					continue
+				}
+			}
+			collection.Posts = append(collection.Posts, post)
+		}
+		s.preparePosts(collection.Posts...)
+	}
+	return nil
+}
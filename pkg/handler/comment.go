@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// GetComments swagger:route GET /post/comments post getComments
+//
+// Returns the comments of a post as threads, replies are nested below the
+// comment they answer
+//
+// responses:
+//     200: []Comment
+//     400: description: bad request
+//     401: description: user is not in the group of the post
+//     404: description: post not found
+//     500: description: internal server error
+func (s *Handler) GetComments(w http.ResponseWriter, r *http.Request) (error, int) {
+	post, err, status := s.accessiblePost(w, r)
+	if post == nil {
+		return err, status
+	}
+	comments, err := s.commentRepo.SelectByPost(r.Context(), post)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return WriteJsonResp(w, commentThreads(comments))
+}
+
+// CreateComment swagger:route POST /post/comments post createComment
+//
+// Comments a post or replies to another comment of the post
+//
+// responses:
+//     200: uidResponse
+//     400: description: bad request
+//     401: description: user is not in the group of the post
+//     404: description: post not found
+//     500: description: internal server error
+func (s *Handler) CreateComment(w http.ResponseWriter, r *http.Request) (error, int) {
+	details := &model.CreateCommentRequestBody{}
+	if err := json.NewDecoder(r.Body).Decode(&details.Info); err != nil {
+		return logResponse(w, "error encoding json",
+			s.rlog.WithFields(logrus.Fields{}).WithError(err), http.StatusBadRequest)
+	}
+	post, err, status := s.accessiblePost(w, r)
+	if post == nil {
+		return err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	comment := &model.Comment{
+		PostID:  post.ID,
+		UserID:  user.ID,
+		Content: details.Info.Content,
+	}
+	if err := s.checkCommentContent(comment); err != nil {
+		return logResponse(w, err.Error(), s.rlog.WithField("post_uid", post.UniqueID), http.StatusBadRequest)
+	}
+	parentAuthor := 0
+	if details.Info.ParentUid != "" {
+		parent, err := s.commentRepo.GetByUid(r.Context(), details.Info.ParentUid)
+		if err != nil && err != sql.ErrNoRows {
+			return err, http.StatusInternalServerError
+		}
+		if err == sql.ErrNoRows || parent.PostID != post.ID {
+			return logResponse(w, "comment to reply to not found",
+				s.rlog.WithField("parent_uid", details.Info.ParentUid), http.StatusBadRequest)
+		}
+		comment.ParentID = sql.NullInt32{Int32: int32(parent.ID), Valid: true}
+		parentAuthor = parent.UserID
+	}
+	comment.UniqueID, err = generateUid(s.commentRepo, r)
+	if err != nil || comment.UniqueID == "" {
+		return err, http.StatusInternalServerError
+	}
+	if err := s.commentRepo.Persist(r.Context(), comment); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if _, err := s.recordMentions(r.Context(), user, post, comment, comment.Content); err != nil {
+		s.log.WithField("comment", comment.UniqueID).WithError(err).Errorln("recording mentions failed")
+	}
+	comment.Author = user.Name
+	e := s.postEvent(r.Context(), model.EventCommentCreated, user, post)
+	e.Data = comment
+	e.Recipients = []int{post.UserID}
+	if details.Info.ParentUid != "" {
+		e.Recipients = append(e.Recipients, parentAuthor)
+	}
+	s.publish(e)
+	return WriteJsonResp(w, &model.UidResponse{UniqueID: comment.UniqueID})
+}
+
+// EditComment swagger:route POST /post/comments/edit post editComment
+//
+// Changes the content of a comment, only its author may edit it
+//
+// responses:
+//     200: description: successfully edited comment
+//     400: description: bad request
+//     401: description: user is not the author
+//     404: description: comment not found
+//     500: description: internal server error
+func (s *Handler) EditComment(w http.ResponseWriter, r *http.Request) (error, int) {
+	details := &model.EditCommentRequestBody{}
+	if err := json.NewDecoder(r.Body).Decode(&details.Info); err != nil {
+		return logResponse(w, "error encoding json",
+			s.rlog.WithFields(logrus.Fields{}).WithError(err), http.StatusBadRequest)
+	}
+	comment, err, status := s.currentComment(w, r)
+	if comment == nil {
+		return err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if comment.UserID != user.ID {
+		return logResponse(w, "only the author may edit a comment",
+			s.rlog.WithField("comment_uid", comment.UniqueID), http.StatusUnauthorized)
+	}
+	comment.Content = details.Info.Content
+	if err := s.checkCommentContent(comment); err != nil {
+		return logResponse(w, err.Error(), s.rlog.WithField("comment_uid", comment.UniqueID), http.StatusBadRequest)
+	}
+	if err := s.commentRepo.Update(r.Context(), comment); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// RemoveComment swagger:route GET /post/comments/remove post removeComment
+//
+// Removes a comment together with its replies. Comments may be removed by
+// their author and by the admin of the group of the post.
+//
+// responses:
+//     200: description: successfully removed comment
+//     400: description: bad request
+//     401: description: user may not remove the comment
+//     404: description: comment not found
+//     500: description: internal server error
+func (s *Handler) RemoveComment(w http.ResponseWriter, r *http.Request) (error, int) {
+	comment, err, status := s.currentComment(w, r)
+	if comment == nil {
+		return err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if comment.UserID != user.ID {
+		post, err := s.postRepo.GetByID(r.Context(), comment.PostID)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+		admin := false
+		if post.GroupID.Valid {
+			group, err := s.groupRepo.GetByID(r.Context(), int(post.GroupID.Int32))
+			if err != nil {
+				return err, http.StatusInternalServerError
+			}
+			admin = group.AdminID == user.ID
+		}
+		if !admin {
+			return logResponse(w, "only the author or the group admin may remove a comment",
+				s.rlog.WithField("comment_uid", comment.UniqueID), http.StatusUnauthorized)
+		}
+	}
+	if err := s.commentRepo.Remove(r.Context(), comment); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// currentComment fetches the comment of the request. If the returned
+// comment is nil, the error and status have to be returned by the calling handler.
+func (s *Handler) currentComment(w http.ResponseWriter, r *http.Request) (*model.Comment, error, int) {
+	commentUid := r.URL.Query().Get("comment_uid")
+	if commentUid == "" {
+		err, status := ErrMissingParam(w, "comment_uid", s.rlog)
+		return nil, err, status
+	}
+	comment, err := s.commentRepo.GetByUid(r.Context(), commentUid)
+	if err == sql.ErrNoRows {
+		err, status := logResponse(w, "comment not found",
+			s.rlog.WithField("comment_uid", commentUid), http.StatusNotFound)
+		return nil, err, status
+	}
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	return comment, nil, http.StatusOK
+}
+
+func (s *Handler) checkCommentContent(comment *model.Comment) error {
+	if comment.Content == "" {
+		return errors.New("comment without content")
+	}
+	if s.config.MaxTextSize > 0 && int64(len(comment.Content)) > s.config.MaxTextSize {
+		return errors.New("comment exceeds the maximum size")
+	}
+	return nil
+}
+
+// commentThreads nests the replies below their comments, the comments have
+// to be sorted by creation
+func commentThreads(comments []*model.Comment) []*model.Comment {
+	byID := make(map[int]*model.Comment, len(comments))
+	threads := []*model.Comment{}
+	for _, comment := range comments {
+		comment.Replies = []*model.Comment{}
+		byID[comment.ID] = comment
+	}
+	for _, comment := range comments {
+		if parent, ok := byID[int(comment.ParentID.Int32)]; ok && comment.ParentID.Valid {
+			parent.Replies = append(parent.Replies, comment)
+		} else {
+			threads = append(threads, comment)
+		}
+	}
+	return threads
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"net/http"
 
+	"gitlab.com/innoserver/pkg/method"
 	"gitlab.com/innoserver/pkg/model"
 )
 
@@ -14,10 +15,29 @@ import (
 //     200: Configuration
 func (s *Handler) GetConfig(w http.ResponseWriter, r *http.Request) (error, int) {
 	config := &model.Configuration{
-		MaxImageSize: s.config.MaxImageSize,
-		MaxVideoSize: s.config.MaxVideoSize,
-		ImagePath:    s.config.ImagePath,
-		VideoPath:    s.config.VideoPath,
+		MaxImageSize:  s.config.MaxImageSize,
+		MaxVideoSize:  s.config.MaxVideoSize,
+		ImagePath:     s.config.ImagePath,
+		VideoPath:     s.config.VideoPath,
+		MaxAudioSize:  s.config.MaxAudioSize,
+		AudioPath:     s.config.AudioPath,
+		MaxTextSize:   s.config.MaxTextSize,
+		MaxSketchSize: s.config.MaxSketchSize,
+		MaxPostMedia:  s.config.MaxPostMedia,
+		UserQuota:     s.config.UserQuota,
+		GroupQuota:    s.config.GroupQuota,
+		Reactions:     s.config.Reactions,
 	}
 	return WriteJsonResp(w, config)
 }
+
+// Methods swagger:route GET /methods getMethods
+//
+// Returns all creativity methods together with the structure their posts
+// have to follow
+//
+// responses:
+//     200: []Method
+func (s *Handler) GetMethods(w http.ResponseWriter, r *http.Request) (error, int) {
+	return WriteJsonResp(w, method.All())
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// publish passes the event to the event bus, if the server has one
+func (s *Handler) publish(e *model.Event) {
+	if s.bus != nil {
+		s.bus.Publish(e)
+	}
+}
+
+// publishPostCreated notifies the author of the parent about a new child post
+func (s *Handler) publishPostCreated(ctx context.Context, actor *model.User, post *model.Post) {
+	e := s.postEvent(ctx, model.EventPostCreated, actor, post)
+	if post.ParentID.Valid {
+		parent, err := s.postRepo.GetByID(ctx, int(post.ParentID.Int32))
+		if err != nil {
+			s.log.WithField("post", post.UniqueID).WithError(err).Errorln("fetching parent of event failed")
+		} else {
+			e.ParentUid = parent.UniqueID
+			e.Recipients = []int{parent.UserID}
+		}
+	}
+	s.publish(e)
+}
+
+// publishOptionsChanged passes the options of the post to the subscribers
+// after they were changed
+func (s *Handler) publishOptionsChanged(ctx context.Context, actor *model.User, post *model.Post) {
+	e := s.postEvent(ctx, model.EventOptionsChanged, actor, post)
+	e.Data = post.Options
+	s.publish(e)
+}
+
+// postEvent prepares an event of the actor about the post. Posts fetched
+// from the database carry the unique ids of their parent and group, for new
+// posts the unique id of the group is looked up.
+func (s *Handler) postEvent(ctx context.Context, kind string, actor *model.User, post *model.Post) *model.Event {
+	if post.GroupUid == "" {
+		e := s.groupEvent(ctx, kind, actor, post.GroupID)
+		e.PostUid = post.UniqueID
+		e.ParentUid = post.ParentUid
+		return e
+	}
+	return &model.Event{
+		Type:      kind,
+		ActorID:   actor.ID,
+		Actor:     actor.Name,
+		GroupID:   post.GroupID,
+		GroupUid:  post.GroupUid,
+		PostUid:   post.UniqueID,
+		ParentUid: post.ParentUid,
+	}
+}
+
+// groupEvent prepares an event of the actor within the group, events
+// without a group concern public content
+func (s *Handler) groupEvent(ctx context.Context, kind string, actor *model.User, groupID sql.NullInt32) *model.Event {
+	e := &model.Event{
+		Type:    kind,
+		ActorID: actor.ID,
+		Actor:   actor.Name,
+		GroupID: groupID,
+	}
+	if groupID.Valid {
+		group, err := s.groupRepo.GetByID(ctx, int(groupID.Int32))
+		if err != nil {
+			s.log.WithField("group_id", groupID.Int32).WithError(err).Errorln("fetching group of event failed")
+		} else {
+			e.GroupUid = group.UniqueID
+		}
+	}
+	return e
+}
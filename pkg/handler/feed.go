@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// The number of feed items returned if no limit is requested
+const defaultFeedLimit = 20
+
+// The largest page of the feed
+const maxFeedLimit = 100
+
+// The order of items created at the same time, the feed is sorted by
+// creation time, rank and id, all descending
+var feedRanks = map[string]int{
+	model.FeedItemMember:  0,
+	model.FeedItemPost:    1,
+	model.FeedItemComment: 2,
+}
+
+// Feed swagger:route GET /feed feed feed
+//
+// Returns the latest posts, comments and new members of all groups of the
+// current user. The cursor of the response fetches the next page.
+//
+// responses:
+//     200: Feed
+//     400: description: bad request
+//     500: description: internal server error
+func (s *Handler) Feed(w http.ResponseWriter, r *http.Request) (error, int) {
+	limit := uint64(defaultFeedLimit)
+	if count := r.URL.Query().Get("limit"); count != "" {
+		icount, err := strconv.ParseUint(count, 10, 64)
+		if err != nil || icount == 0 || icount > maxFeedLimit {
+			return logResponse(w, "invalid limit",
+				s.rlog.WithField("limit", count), http.StatusBadRequest)
+		}
+		limit = icount
+	}
+	var last *model.FeedItem
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		var err error
+		if last, err = parseFeedCursor(cursor); err != nil {
+			return logResponse(w, "invalid cursor",
+				s.rlog.WithField("cursor", cursor).WithError(err), http.StatusBadRequest)
+		}
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	groups, err := s.groupRepo.SelectByUser(r.Context(), user)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	groupIDs := make([]int, len(groups))
+	for i, group := range groups {
+		groupIDs[i] = group.ID
+	}
+	// one more item than requested tells whether another page follows
+	items, err := s.selectFeed(r.Context(), groupIDs, last, limit+1)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	feed := &model.Feed{Items: items}
+	if uint64(len(items)) > limit {
+		feed.Items = items[:limit]
+		feed.Cursor = formatFeedCursor(feed.Items[limit-1])
+	}
+	return WriteJsonResp(w, feed)
+}
+
+// selectFeed merges up to limit items of every kind following the last
+// item into one list, sorted like the feed
+func (s *Handler) selectFeed(ctx context.Context, groupIDs []int, last *model.FeedItem,
+	limit uint64) ([]*model.FeedItem, error) {
+	items := []*model.FeedItem{}
+	posts, err := s.postRepo.SelectFeed(ctx, groupIDs, feedCursorOf(last, model.FeedItemPost), limit)
+	if err != nil {
+		return nil, err
+	}
+	s.preparePosts(posts...)
+	for _, post := range posts {
+		items = append(items, &model.FeedItem{Type: model.FeedItemPost, ID: post.ID,
+			GroupUid: post.GroupUid, Post: post, CreatedAt: post.CreatedAt})
+	}
+	comments, err := s.commentRepo.SelectFeed(ctx, groupIDs, feedCursorOf(last, model.FeedItemComment), limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range comments {
+		items = append(items, &model.FeedItem{Type: model.FeedItemComment, ID: comment.ID,
+			GroupUid: comment.GroupUid, Comment: comment, CreatedAt: comment.CreatedAt})
+	}
+	members, err := s.groupRepo.SelectMemberships(ctx, groupIDs, feedCursorOf(last, model.FeedItemMember), limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		items = append(items, &model.FeedItem{Type: model.FeedItemMember, ID: member.ID,
+			GroupUid: member.GroupUid, Member: member, CreatedAt: member.CreatedAt})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		if feedRanks[a.Type] != feedRanks[b.Type] {
+			return feedRanks[a.Type] > feedRanks[b.Type]
+		}
+		return a.ID > b.ID
+	})
+	if uint64(len(items)) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// feedCursorOf returns the position following the last item among the
+// items of a kind. Items of lower rank created at the same time as the last
+// item all follow it, those of higher rank all precede it.
+func feedCursorOf(last *model.FeedItem, kind string) *model.FeedCursor {
+	if last == nil {
+		return nil
+	}
+	cursor := &model.FeedCursor{Time: last.CreatedAt, ID: last.ID}
+	switch {
+	case feedRanks[kind] < feedRanks[last.Type]:
+		cursor.ID = math.MaxInt32
+	case feedRanks[kind] > feedRanks[last.Type]:
+		cursor.ID = 0
+	}
+	return cursor
+}
+
+// formatFeedCursor encodes the position of the item for the client
+func formatFeedCursor(item *model.FeedItem) string {
+	cursor := fmt.Sprintf("%d:%s:%d", item.CreatedAt.UnixNano(), item.Type, item.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor))
+}
+
+// parseFeedCursor decodes a cursor into the item it points at
+func parseFeedCursor(cursor string) (*model.FeedItem, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(string(decoded), ":")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed cursor")
+	}
+	if _, ok := feedRanks[parts[1]]; !ok {
+		return nil, errors.New("unknown item type " + parts[1])
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	return &model.FeedItem{Type: parts[1], ID: id, CreatedAt: time.Unix(0, nanos).UTC()}, nil
+}
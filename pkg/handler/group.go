@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -116,6 +118,10 @@ func (s *Handler) AddUserToGroup(w http.ResponseWriter, r *http.Request) (error,
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
+	e := s.groupEvent(r.Context(), model.EventMemberAdded, curUser, sql.NullInt32{Int32: int32(group.ID), Valid: true})
+	e.Data = user.Name
+	e.Recipients = []int{user.ID}
+	s.publish(e)
 	return nil, http.StatusOK
 }
 
@@ -148,7 +154,7 @@ func (s *Handler) ListGroupMembers(w http.ResponseWriter, r *http.Request) (erro
 // Returns infos about specific group
 //
 // responses:
-//     200: Group
+//     200: GroupWithUsage
 //     400: description: bad request
 //     500: description: server internal error
 func (s *Handler) GroupInfo(w http.ResponseWriter, r *http.Request) (error, int) {
@@ -160,7 +166,11 @@ func (s *Handler) GroupInfo(w http.ResponseWriter, r *http.Request) (error, int)
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
-	return WriteJsonResp(w, group)
+	usage, err := s.groupUsage(r.Context(), group)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return WriteJsonResp(w, &model.GroupWithUsage{Group: *group, Usage: usage})
 }
 
 // SetVisibility swagger:route GET /group/setvisibility group setVisibility
@@ -226,6 +236,10 @@ func (s *Handler) JoinGroup(w http.ResponseWriter, r *http.Request) (error, int)
 		if err != nil {
 			return err, http.StatusInternalServerError
 		}
+		e := s.groupEvent(r.Context(), model.EventMemberAdded, user, sql.NullInt32{Int32: int32(group.ID), Valid: true})
+		e.Data = user.Name
+		e.Recipients = []int{group.AdminID}
+		s.publish(e)
 		return nil, http.StatusOK
 	}
 	return logResponse(w, "couldn't add requesting user to group",
@@ -251,11 +265,73 @@ func (s *Handler) RemoveGroup(w http.ResponseWriter, r *http.Request) (error, in
 			s.rlog.WithField("group_uid", groupUid).WithError(err),
 			http.StatusInternalServerError)
 	}
-	s.groupRepo.RemoveGroup(r.Context(), group)
+	removed, err := s.collectGroupPosts(r.Context(), group)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	err = s.groupRepo.RemoveGroup(r.Context(), group)
 	if err != nil {
 		return logResponse(w, "error removing group from db",
 			s.rlog.WithField("group_uid", groupUid).WithError(err),
 			http.StatusInternalServerError)
 	}
+	s.releasePostFiles(r.Context(), removed)
+	return nil, http.StatusOK
+}
+
+// collectGroupPosts returns all posts of a group including their descendants
+func (s *Handler) collectGroupPosts(ctx context.Context, group *model.Group) ([]*model.Post, error) {
+	posts, err := s.postRepo.SelectByGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	collected := []*model.Post{}
+	seen := map[int]bool{}
+	for _, post := range posts {
+		tree, err := s.collectPostTree(ctx, post)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range tree {
+			if !seen[p.ID] {
+				seen[p.ID] = true
+				collected = append(collected, p)
+			}
+		}
+	}
+	return collected, nil
+}
+
+// checkGroupAccess ensures that the user may access content of the group,
+// like groupMiddleware does for requests with a group_uid
+func (s *Handler) checkGroupAccess(w http.ResponseWriter, r *http.Request, groupID sql.NullInt32,
+	user *model.User) (error, int) {
+	accessible, err := s.groupAccessible(r.Context(), groupID, user)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if !accessible {
+		return logResponse(w, "user is not in the group",
+			s.rlog.WithFields(logrus.Fields{
+				"user":     user.Name,
+				"group_id": groupID.Int32,
+			}), http.StatusUnauthorized)
+	}
 	return nil, http.StatusOK
 }
+
+// groupAccessible reports whether the user may see content of the group,
+// content without a group is public
+func (s *Handler) groupAccessible(ctx context.Context, groupID sql.NullInt32, user *model.User) (bool, error) {
+	if !groupID.Valid {
+		return true, nil
+	}
+	group, err := s.groupRepo.GetByID(ctx, int(groupID.Int32))
+	if err != nil {
+		return false, err
+	}
+	if group.Public {
+		return true, nil
+	}
+	return s.groupRepo.IsUserInGroup(ctx, user, group)
+}
@@ -3,11 +3,15 @@ package handler
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 
+	"gitlab.com/innoserver/pkg/event"
+	"gitlab.com/innoserver/pkg/live"
 	"gitlab.com/innoserver/pkg/model"
+	"gitlab.com/innoserver/pkg/webhook"
 )
 
 type userRepository interface {
@@ -24,18 +28,28 @@ type postRepository interface {
 	Persist(ctx context.Context, post *model.Post) error
 	GetByTitleInGroup(ctx context.Context, title string, group *model.Group, limit int64) ([]*model.Post, error)
 	GetByUid(ctx context.Context, uid string) (*model.Post, error)
+	GetByID(ctx context.Context, id int) (*model.Post, error)
 	SelectLatest(ctx context.Context, limit uint64) ([]*model.Post, error)
 	SelectLatestOfGroup(ctx context.Context, group *model.Group, limit uint64) ([]*model.Post, error)
+	SelectByGroup(ctx context.Context, group *model.Group) ([]*model.Post, error)
+	SelectByCollection(ctx context.Context, collection *model.Collection) ([]*model.Post, error)
+	SelectFeed(ctx context.Context, groupIDs []int, before *model.FeedCursor, limit uint64) ([]*model.Post, error)
+	SumSizeByUser(ctx context.Context, user *model.User) (int64, error)
+	SumSizeByGroup(ctx context.Context, group *model.Group) (int64, error)
 	AddOptions(ctx context.Context, post *model.Post, options []*model.Option) error
 	RemoveOptions(ctx context.Context, post *model.Post) error
 	SetOptions(ctx context.Context, post *model.Post, options []*model.Option) error
+	UpsertOptions(ctx context.Context, post *model.Post, options []*model.Option) error
+	RemoveOption(ctx context.Context, post *model.Post, key string) error
 	SelectOptions(ctx context.Context, post *model.Post) ([]*model.Option, error)
 	RemovePost(ctx context.Context, post *model.Post) error
+	AddMedia(ctx context.Context, post *model.Post, media []*model.PostMedia) error
 }
 
 type groupRepository interface {
 	uniqueID
 	GetByUid(ctx context.Context, uid string) (*model.Group, error)
+	GetByID(ctx context.Context, id int) (*model.Group, error)
 	Persist(ctx context.Context, group *model.Group) error
 	AddUserToGroup(ctx context.Context, user *model.User, group *model.Group) error
 	IsUserInGroup(ctx context.Context, user *model.User, group *model.Group) (bool, error)
@@ -43,6 +57,102 @@ type groupRepository interface {
 	UpdateVisibility(ctx context.Context, group *model.Group) error
 	SelectByUser(ctx context.Context, user *model.User) ([]*model.Group, error)
 	RemoveGroup(ctx context.Context, group *model.Group) error
+	SelectMemberships(ctx context.Context, groupIDs []int, before *model.FeedCursor, limit uint64) ([]*model.Membership, error)
+}
+
+type uploadRepository interface {
+	uniqueID
+	Persist(ctx context.Context, upload *model.Upload) error
+	GetByUid(ctx context.Context, uid string) (*model.Upload, error)
+	UpdateReceived(ctx context.Context, upload *model.Upload) error
+	Claim(ctx context.Context, upload *model.Upload) (bool, error)
+	Release(ctx context.Context, upload *model.Upload) error
+	Remove(ctx context.Context, upload *model.Upload) error
+}
+
+type blobRepository interface {
+	Acquire(ctx context.Context, blob *model.Blob) (bool, error)
+	Release(ctx context.Context, fType int, path string, removeFile func() error) error
+}
+
+type sessionRepository interface {
+	uniqueID
+	Persist(ctx context.Context, session *model.Session) error
+	GetByUid(ctx context.Context, uid string) (*model.Session, error)
+	UpdateState(ctx context.Context, session *model.Session, previousState, previousRound int) (bool, error)
+	AddParticipant(ctx context.Context, participant *model.SessionParticipant) (bool, error)
+	SelectParticipants(ctx context.Context, session *model.Session) ([]*model.SessionParticipant, error)
+	AddContribution(ctx context.Context, contribution *model.SessionContribution) error
+	SelectContributions(ctx context.Context, session *model.Session) ([]*model.SessionContribution, error)
+}
+
+type votingRepository interface {
+	uniqueID
+	Persist(ctx context.Context, voting *model.Voting) error
+	GetByUid(ctx context.Context, uid string) (*model.Voting, error)
+	UpdateState(ctx context.Context, voting *model.Voting) error
+	CastVote(ctx context.Context, vote *model.Vote, budget int) (int, error)
+	RetractVote(ctx context.Context, vote *model.Vote) error
+	SelectVotesOfUser(ctx context.Context, voting *model.Voting, user *model.User) ([]*model.Vote, error)
+	SelectTallies(ctx context.Context, voting *model.Voting) ([]*model.VoteTally, error)
+	SelectVoters(ctx context.Context, voting *model.Voting) ([]int, error)
+}
+
+type commentRepository interface {
+	uniqueID
+	Persist(ctx context.Context, comment *model.Comment) error
+	GetByUid(ctx context.Context, uid string) (*model.Comment, error)
+	SelectByPost(ctx context.Context, post *model.Post) ([]*model.Comment, error)
+	Update(ctx context.Context, comment *model.Comment) error
+	Remove(ctx context.Context, comment *model.Comment) error
+	SelectFeed(ctx context.Context, groupIDs []int, before *model.FeedCursor, limit uint64) ([]*model.FeedComment, error)
+}
+
+type reactionRepository interface {
+	Add(ctx context.Context, reaction *model.Reaction) error
+	Remove(ctx context.Context, reaction *model.Reaction) error
+	SelectByPost(ctx context.Context, post *model.Post) ([]*model.Reaction, error)
+}
+
+type collectionRepository interface {
+	uniqueID
+	Persist(ctx context.Context, collection *model.Collection) error
+	GetByUid(ctx context.Context, uid string) (*model.Collection, error)
+	GetDefault(ctx context.Context, user *model.User) (*model.Collection, error)
+	SelectByUser(ctx context.Context, user *model.User) ([]*model.Collection, error)
+	Remove(ctx context.Context, collection *model.Collection) error
+	AddPost(ctx context.Context, collection *model.Collection, post *model.Post) error
+	RemovePost(ctx context.Context, collection *model.Collection, post *model.Post) error
+}
+
+type mentionRepository interface {
+	Persist(ctx context.Context, mention *model.Mention) error
+	SelectByUser(ctx context.Context, user *model.User) ([]*model.Mention, error)
+}
+
+type notificationRepository interface {
+	GetByUid(ctx context.Context, uid string) (*model.Notification, error)
+	SelectByUser(ctx context.Context, user *model.User, unread bool, limit uint64) ([]*model.Notification, error)
+	MarkRead(ctx context.Context, notification *model.Notification) error
+	MarkAllRead(ctx context.Context, user *model.User) error
+	SelectPreferences(ctx context.Context, user *model.User) ([]*model.NotificationPreference, error)
+	SetPreference(ctx context.Context, preference *model.NotificationPreference) error
+}
+
+type deviceRepository interface {
+	Persist(ctx context.Context, device *model.Device) error
+	GetByToken(ctx context.Context, token string) (*model.Device, error)
+	Remove(ctx context.Context, device *model.Device) error
+}
+
+type webhookRepository interface {
+	uniqueID
+	Persist(ctx context.Context, webhook *model.Webhook) error
+	GetByUid(ctx context.Context, uid string) (*model.Webhook, error)
+	SelectByGroup(ctx context.Context, groupID int) ([]*model.Webhook, error)
+	Remove(ctx context.Context, webhook *model.Webhook) error
+	AddDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	SelectDeliveries(ctx context.Context, webhook *model.Webhook, limit uint64) ([]*model.WebhookDelivery, error)
 }
 
 type uniqueID interface {
@@ -50,9 +160,24 @@ type uniqueID interface {
 }
 
 type Handler struct {
-	userRepo  userRepository
-	postRepo  postRepository
-	groupRepo groupRepository
+	userRepo         userRepository
+	postRepo         postRepository
+	groupRepo        groupRepository
+	uploadRepo       uploadRepository
+	blobRepo         blobRepository
+	sessionRepo      sessionRepository
+	votingRepo       votingRepository
+	commentRepo      commentRepository
+	reactionRepo     reactionRepository
+	collectionRepo   collectionRepository
+	mentionRepo      mentionRepository
+	notificationRepo notificationRepository
+	deviceRepo       deviceRepository
+	webhookRepo      webhookRepository
+	bus              *event.Bus
+	hub              *live.Hub
+	eventLog         *live.Log
+	webhooks         *webhook.Deliverer
 
 	config *model.Config
 	log    *logrus.Entry
@@ -73,6 +198,36 @@ func NewHandler(injections ...interface{}) *Handler {
 			handler.postRepo = v
 		case groupRepository:
 			handler.groupRepo = v
+		case uploadRepository:
+			handler.uploadRepo = v
+		case blobRepository:
+			handler.blobRepo = v
+		case sessionRepository:
+			handler.sessionRepo = v
+		case votingRepository:
+			handler.votingRepo = v
+		case commentRepository:
+			handler.commentRepo = v
+		case reactionRepository:
+			handler.reactionRepo = v
+		case collectionRepository:
+			handler.collectionRepo = v
+		case mentionRepository:
+			handler.mentionRepo = v
+		case notificationRepository:
+			handler.notificationRepo = v
+		case deviceRepository:
+			handler.deviceRepo = v
+		case webhookRepository:
+			handler.webhookRepo = v
+		case *event.Bus:
+			handler.bus = v
+		case *live.Hub:
+			handler.hub = v
+		case *live.Log:
+			handler.eventLog = v
+		case *webhook.Deliverer:
+			handler.webhooks = v
 		case *model.Config:
 			handler.config = v
 		case [2]*logrus.Logger:
@@ -87,9 +242,11 @@ func (s *Handler) SetupRouter() {
 	s.router = mux.NewRouter()
 
 	s.router.Path("/config").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.GetConfig))
+	s.router.Path("/methods").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.GetMethods))
 
 	userRouter := s.router.PathPrefix("/user").Subrouter()
 	userRouter.Path("/info").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.UserInfo))
+	userRouter.Path("/mentions").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.ListMentions))
 
 	swaggerRouter := s.router.PathPrefix("/swagger").Subrouter()
 	swaggerRouter.Path("").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.Swagger))
@@ -101,15 +258,71 @@ func (s *Handler) SetupRouter() {
 	postRouter := s.router.PathPrefix("/post").Subrouter()
 	postRouter.Path("/remove").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.RemovePost))
 	postRouter.Path("/upload").Methods("POST", "GET", "OPTIONS").HandlerFunc(errorWrapper(s.UploadPost))
+	postRouter.Path("/upload/init").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.InitUpload))
+	postRouter.Path("/upload/status").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.UploadStatus))
+	postRouter.Path("/upload/chunk").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.UploadChunk))
+	postRouter.Path("/upload/complete").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.CompleteUpload))
 	postRouter.Path("/get").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.GetPost))
 	postRouter.Path("/find").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.Find))
+	postRouter.Path("/lotusblossom").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.LotusBlossom))
+	postRouter.Path("/scamper").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.ScamperSession))
 	postRouter.Path("/getchildren").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.GetChildren))
 	postRouter.Path("/selectlatest").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.FetchLatestPosts))
 	postRouter.Path("/setoptions").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.SetOptions))
 	postRouter.Path("/addoptions").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.AddOptions))
 	postRouter.Path("/removeoptions").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.RemoveOptions))
+	postRouter.Path("/upsertoptions").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.UpsertOptions))
+	postRouter.Path("/removeoption").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.RemoveOption))
+	postRouter.Path("/comments").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.GetComments))
+	postRouter.Path("/comments").Methods("POST").HandlerFunc(errorWrapper(s.CreateComment))
+	postRouter.Path("/comments/edit").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.EditComment))
+	postRouter.Path("/comments/remove").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.RemoveComment))
+	postRouter.Path("/react").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.React))
+	postRouter.Path("/unreact").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.Unreact))
+	postRouter.Path("/reactions").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.ListReactions))
 	postRouter.Use(authenticationMiddleware)
 
+	sessionRouter := s.router.PathPrefix("/session").Subrouter()
+	sessionRouter.Path("/create").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.CreateSession))
+	sessionRouter.Path("/join").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.JoinSession))
+	sessionRouter.Path("/start").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.StartSession))
+	sessionRouter.Path("/close").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.CloseSession))
+	sessionRouter.Path("/info").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.SessionInfo))
+	sessionRouter.Path("/contribute").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.ContributeToSession))
+
+	voteRouter := s.router.PathPrefix("/vote").Subrouter()
+	voteRouter.Path("/create").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.CreateVoting))
+	voteRouter.Path("/cast").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.CastVote))
+	voteRouter.Path("/retract").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.RetractVote))
+	voteRouter.Path("/close").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.CloseVoting))
+	voteRouter.Path("/results").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.VotingResults))
+
+	collectionRouter := s.router.PathPrefix("/collection").Subrouter()
+	collectionRouter.Path("/create").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.CreateCollection))
+	collectionRouter.Path("/list").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.ListCollections))
+	collectionRouter.Path("/get").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.GetCollection))
+	collectionRouter.Path("/remove").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.RemoveCollection))
+	collectionRouter.Path("/add").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.CollectPost))
+	collectionRouter.Path("/removepost").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.UncollectPost))
+
+	notificationRouter := s.router.PathPrefix("/notifications").Subrouter()
+	notificationRouter.Path("/list").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.ListNotifications))
+	notificationRouter.Path("/read").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.ReadNotification))
+	notificationRouter.Path("/readall").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.ReadAllNotifications))
+	notificationRouter.Path("/preferences").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.NotificationPreferences))
+	notificationRouter.Path("/preferences").Methods("POST").HandlerFunc(errorWrapper(s.SetNotificationPreferences))
+	notificationRouter.Path("/devices").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.RegisterDevice))
+	notificationRouter.Path("/devices/remove").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.UnregisterDevice))
+
+	liveRouter := s.router.PathPrefix("/live").Subrouter()
+	liveRouter.Path("").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.LiveFeed))
+
+	feedRouter := s.router.PathPrefix("/feed").Subrouter()
+	feedRouter.Path("").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.Feed))
+
+	eventRouter := s.router.PathPrefix("/events").Subrouter()
+	eventRouter.Path("").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.EventStream))
+
 	groupRouter := s.router.PathPrefix("/group").Subrouter()
 	groupRouter.Path("/join").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.JoinGroup))
 	groupRouter.Path("/info").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.GroupInfo))
@@ -122,21 +335,37 @@ func (s *Handler) SetupRouter() {
 	adminRouter.Path("/adduser").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.AddUserToGroup))
 	adminRouter.Path("/setvisibility").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.SetVisibility))
 	adminRouter.Path("/remove").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.RemoveGroup))
+	adminRouter.Path("/webhooks/create").Methods("POST", "OPTIONS").HandlerFunc(errorWrapper(s.CreateWebhook))
+	adminRouter.Path("/webhooks/list").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.ListWebhooks))
+	adminRouter.Path("/webhooks/remove").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.RemoveWebhook))
+	adminRouter.Path("/webhooks/test").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.TestWebhook))
+	adminRouter.Path("/webhooks/deliveries").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.WebhookDeliveries))
 
 	assetRouter := s.router.PathPrefix("/assets").Subrouter()
-	assetRouter.PathPrefix("/images").Handler(http.StripPrefix("/assets/images",
-		http.FileServer(http.Dir("assets/images/"))))
-	assetRouter.PathPrefix("/videos").Handler(http.StripPrefix("/assets/videos",
-		http.FileServer(http.Dir("assets/videos/"))))
+	assetRouter.Path("/{kind}/{file}").Methods("GET", "OPTIONS").HandlerFunc(errorWrapper(s.ServeMedia))
 
 	s.router.Use(corsMiddleware)
 	s.router.Use(logMiddleware)
 	authRouter.Use(keyMiddleware)
 	postRouter.Use(keyMiddleware)
 	groupRouter.Use(keyMiddleware)
+	sessionRouter.Use(keyMiddleware)
+	voteRouter.Use(keyMiddleware)
+	collectionRouter.Use(keyMiddleware)
+	notificationRouter.Use(keyMiddleware)
+	liveRouter.Use(keyMiddleware)
+	feedRouter.Use(keyMiddleware)
+	eventRouter.Use(keyMiddleware)
 	userRouter.Use(keyMiddleware)
 	userRouter.Use(authenticationMiddleware)
 	groupRouter.Use(authenticationMiddleware)
+	sessionRouter.Use(authenticationMiddleware)
+	voteRouter.Use(authenticationMiddleware)
+	collectionRouter.Use(authenticationMiddleware)
+	notificationRouter.Use(authenticationMiddleware)
+	liveRouter.Use(authenticationMiddleware)
+	feedRouter.Use(authenticationMiddleware)
+	eventRouter.Use(authenticationMiddleware)
 	postRouter.Use(authenticationMiddleware)
 	inGroupRouter.Use(groupMiddleware)
 	postRouter.Use(groupMiddleware)
@@ -144,6 +373,9 @@ func (s *Handler) SetupRouter() {
 }
 
 func (s *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/events") {
+		moveQueryCredentials(r)
+	}
 	s.log = s.log.WithFields(logrus.Fields{"url": r.URL.String()})
 	r = r.WithContext(context.WithValue(r.Context(), "config", s.config))
 	r = r.WithContext(context.WithValue(r.Context(), "user_repository", &s.userRepo))
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"gitlab.com/innoserver/pkg/live"
+	"gitlab.com/innoserver/pkg/model"
+)
+
+const (
+	liveWriteWait  = 10 * time.Second
+	livePongWait   = 60 * time.Second
+	livePingPeriod = livePongWait * 9 / 10
+	// how often the access to the subscriptions is checked again, so users
+	// removed from a group stop receiving its events
+	liveRecheckPeriod = time.Minute
+)
+
+// LiveFeed swagger:route GET /live live liveFeed
+//
+// Upgrades the connection to a websocket delivering the events of groups and
+// posts in real time. Clients send LiveCommand messages to subscribe to a
+// group or to a post and its children, the server answers with a LiveReply
+// and sends matching events afterwards. Subscriptions the user loses access
+// to are dropped with an unsubscribe reply.
+//
+// responses:
+//     101: description: switching to the websocket protocol
+//     401: description: user is not authenticated
+//     503: description: live events are not available
+func (s *Handler) LiveFeed(w http.ResponseWriter, r *http.Request) (error, int) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if s.hub == nil {
+		return logResponse(w, "live events are not available", s.rlog.WithField("user", user.Name),
+			http.StatusServiceUnavailable)
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: s.checkOrigin}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already answered the request
+		s.log.WithField("user", user.Name).WithError(err).Errorln("upgrading live connection failed")
+		return nil, http.StatusBadRequest
+	}
+	conn := &liveConn{ws: ws}
+	defer ws.Close()
+	client := s.hub.Register()
+	defer s.hub.Unregister(client)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.readLiveCommands(r.Context(), conn, client, user)
+	}()
+	ticker := time.NewTicker(livePingPeriod)
+	defer ticker.Stop()
+	recheck := time.NewTicker(liveRecheckPeriod)
+	defer recheck.Stop()
+	for {
+		select {
+		case <-done:
+			return nil, http.StatusOK
+		case e, ok := <-client.Events():
+			if !ok {
+				conn.close()
+				return nil, http.StatusOK
+			}
+			if err := conn.write(e); err != nil {
+				return nil, http.StatusOK
+			}
+		case <-ticker.C:
+			if err := conn.ping(); err != nil {
+				return nil, http.StatusOK
+			}
+		case <-recheck.C:
+			if err := s.recheckLiveSubscriptions(r.Context(), conn, client, user); err != nil {
+				return nil, http.StatusOK
+			}
+		}
+	}
+}
+
+// readLiveCommands handles the commands of the client until the connection
+// breaks
+func (s *Handler) readLiveCommands(ctx context.Context, conn *liveConn, client *live.Client, user *model.User) {
+	conn.ws.SetReadDeadline(time.Now().Add(livePongWait))
+	conn.ws.SetPongHandler(func(string) error {
+		return conn.ws.SetReadDeadline(time.Now().Add(livePongWait))
+	})
+	for {
+		command := &model.LiveCommand{}
+		if err := conn.ws.ReadJSON(command); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				s.log.WithField("user", user.Name).WithError(err).Debugln("live connection closed")
+			}
+			return
+		}
+		reply := &model.LiveReply{Type: command.Action, GroupUid: command.GroupUid, PostUid: command.PostUid}
+		sub := live.Subscription{GroupUid: command.GroupUid, PostUid: command.PostUid}
+		switch {
+		case (sub.GroupUid == "") == (sub.PostUid == ""):
+			reply.Type, reply.Error = "error", "either group_uid or post_uid is required"
+		case command.Action == model.LiveUnsubscribe:
+			client.Unsubscribe(sub)
+		case command.Action != model.LiveSubscribe:
+			reply.Type, reply.Error = "error", "unknown action"
+		default:
+			if err := s.checkLiveSubscription(ctx, sub, user); err != nil {
+				reply.Type, reply.Error = "error", err.Error()
+			} else {
+				client.Subscribe(sub)
+			}
+		}
+		if err := conn.write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// checkLiveSubscription ensures the user may see the events of the
+// subscription, like groupMiddleware does for requests
+func (s *Handler) checkLiveSubscription(ctx context.Context, sub live.Subscription, user *model.User) error {
+	var groupID sql.NullInt32
+	if sub.GroupUid != "" {
+		group, err := s.groupRepo.GetByUid(ctx, sub.GroupUid)
+		if err != nil || group.ID == 0 {
+			return errors.New("group not found")
+		}
+		groupID = sql.NullInt32{Int32: int32(group.ID), Valid: true}
+	} else {
+		post, err := s.postRepo.GetByUid(ctx, sub.PostUid)
+		if err != nil {
+			return errors.New("post not found")
+		}
+		groupID = post.GroupID
+	}
+	accessible, err := s.groupAccessible(ctx, groupID, user)
+	if err != nil {
+		s.log.WithField("user", user.Name).WithError(err).Errorln("checking live subscription failed")
+		return errors.New("internal server error")
+	}
+	if !accessible {
+		return errors.New("user is not in the group")
+	}
+	return nil
+}
+
+// recheckLiveSubscriptions drops the subscriptions the user may not access
+// anymore and tells the client about it. The returned error reports a broken
+// connection.
+func (s *Handler) recheckLiveSubscriptions(ctx context.Context, conn *liveConn, client *live.Client, user *model.User) error {
+	for _, sub := range client.Subscriptions() {
+		err := s.checkLiveSubscription(ctx, sub, user)
+		if err == nil {
+			continue
+		}
+		client.Unsubscribe(sub)
+		reply := &model.LiveReply{Type: model.LiveUnsubscribe, GroupUid: sub.GroupUid, PostUid: sub.PostUid,
+			Error: err.Error()}
+		if err := conn.write(reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOrigin accepts connections from the origins allowed for requests
+func (s *Handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	allowed := s.config.AccessControlAllowOrigin
+	return origin == "" || allowed == "*" || allowed == origin
+}
+
+// liveConn serializes the writes of the event loop and the command reader
+type liveConn struct {
+	ws   *websocket.Conn
+	lock sync.Mutex
+}
+
+func (c *liveConn) write(v interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(liveWriteWait))
+	return c.ws.WriteJSON(v)
+}
+
+func (c *liveConn) ping() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait))
+}
+
+func (c *liveConn) close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseGoingAway, "client too slow"), time.Now().Add(liveWriteWait))
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/method"
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// LotusBlossom swagger:route GET /post/lotusblossom post lotusBlossom
+//
+// Returns a lotus blossom session as one document, containing the center
+// post, its petals and the outer petals, together with all empty cells
+//
+// responses:
+//     200: LotusBlossom
+//     400: description: bad request
+//     401: description: user is not in the group of the post
+//     404: description: post not found
+//     500: description: server internal error
+func (s *Handler) LotusBlossom(w http.ResponseWriter, r *http.Request) (error, int) {
+	center, err, status := s.accessiblePostOf(w, r, "uid")
+	if center == nil {
+		return err, status
+	}
+	if center.ParentID.Valid || center.Method != method.LotusBlossom {
+		return logResponse(w, "post is no lotus blossom center",
+			s.rlog.WithFields(logrus.Fields{
+				"uid":    center.UniqueID,
+				"method": center.Method,
+			}), http.StatusBadRequest)
+	}
+	blossom := &model.LotusBlossom{Center: center, Empty: []*model.LotusCell{}}
+	s.preparePosts(center)
+	blossom.Petals, err = s.lotusPetals(r.Context(), center, 0, 1, blossom)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	blossom.Complete = len(blossom.Empty) == 0
+	return WriteJsonResp(w, blossom)
+}
+
+// lotusPetals arranges the children of parent into the eight petals and
+// records all empty cells within the blossom
+func (s *Handler) lotusPetals(ctx context.Context, parent *model.Post, petal int, depth int,
+	blossom *model.LotusBlossom) ([]*model.LotusPetal, error) {
+	children, err := s.postRepo.SelectByParent(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	s.preparePosts(children...)
+	petals := make([]*model.LotusPetal, method.LotusPetals)
+	for i := range petals {
+		petals[i] = &model.LotusPetal{Position: i + 1}
+	}
+	for _, child := range children {
+		if child.Position >= 1 && child.Position <= method.LotusPetals && petals[child.Position-1].Post == nil {
+			petals[child.Position-1].Post = child
+		}
+	}
+	for _, p := range petals {
+		if p.Post == nil {
+			blossom.Empty = append(blossom.Empty, &model.LotusCell{Petal: petal, Position: p.Position})
+		}
+	}
+	if depth >= method.LotusDepth {
+		return petals, nil
+	}
+	for _, p := range petals {
+		if p.Post == nil {
+			// the outer petals can't be filled before their theme exists
+			for i := 1; i <= method.LotusPetals; i++ {
+				blossom.Empty = append(blossom.Empty, &model.LotusCell{Petal: p.Position, Position: i})
+			}
+			continue
+		}
+		if p.Petals, err = s.lotusPetals(ctx, p.Post, p.Position, depth+1, blossom); err != nil {
+			return nil, err
+		}
+	}
+	return petals, nil
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/method"
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// ServeMedia swagger:route GET /assets/{kind}/{file} assets serveMedia
+//
+// Delivers an uploaded media file. Requests have to carry the expires and
+// signature parameters of the url returned within the post json.
+//
+// responses:
+//     200: description: requested media file
+//     403: description: signature invalid or expired
+//     404: description: media file not found
+func (s *Handler) ServeMedia(w http.ResponseWriter, r *http.Request) (error, int) {
+	vars := mux.Vars(r)
+	kind, file := vars["kind"], vars["file"]
+	dir := mediaDirOfKind(s, kind)
+	if dir == "" || file == "" || file != filepath.Base(file) || strings.HasPrefix(file, ".") {
+		return nil, http.StatusNotFound
+	}
+	expires := r.URL.Query().Get("expires")
+	signature := r.URL.Query().Get("signature")
+	if !verifyMediaSignature(dir+file, expires, signature, s.mediaSecret()) {
+		return logResponse(w, "media signature invalid or expired",
+			s.rlog.WithFields(logrus.Fields{
+				"file":    file,
+				"expires": expires,
+			}), http.StatusForbidden)
+	}
+	http.ServeFile(w, r, "."+dir+file)
+	return nil, http.StatusOK
+}
+
+// preparePosts completes posts before they are written to a json response
+func (s *Handler) preparePosts(posts ...*model.Post) {
+	expires := time.Now().Add(s.mediaUrlExpiry()).Unix()
+	for _, post := range posts {
+		if post == nil {
+			continue
+		}
+		if m, ok := method.Get(post.Method); ok {
+			post.Options = m.Describe(post.Options)
+		}
+		if post.Path != "" {
+			post.Url = signMediaPath(mediaDirOfType(s, post.Type)+post.Path, s.mediaSecret(), expires)
+		}
+		for _, m := range post.Media {
+			m.Url = signMediaPath(mediaDirOfType(s, m.Type)+m.Path, s.mediaSecret(), expires)
+		}
+	}
+}
+
+func (s *Handler) mediaSecret() []byte {
+	if s.config.MediaSecret != "" {
+		return []byte(s.config.MediaSecret)
+	}
+	return []byte(s.config.JwtSecret)
+}
+
+func (s *Handler) mediaUrlExpiry() time.Duration {
+	if s.config.MediaUrlExpiry > 0 {
+		return time.Duration(s.config.MediaUrlExpiry) * time.Second
+	}
+	return time.Hour
+}
+
+// mediaTypes are all post types which are stored with files
+var mediaTypes = []int{model.PostTypeImage, model.PostTypeVideo, model.PostTypeAudio}
+
+func mediaDirOfKind(s *Handler, kind string) string {
+	for _, fType := range mediaTypes {
+		if dir := mediaDirOfType(s, fType); path.Base(dir) == kind {
+			return dir
+		}
+	}
+	return ""
+}
+
+func mediaDirOfType(s *Handler, fType int) string {
+	switch fType {
+	case model.PostTypeImage:
+		return s.config.ImagePath
+	case model.PostTypeAudio:
+		return s.config.AudioPath
+	}
+	return s.config.VideoPath
+}
+
+func mediaSignature(path string, expires string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signMediaPath returns the path extended by an expiry date and a hmac signature
+func signMediaPath(path string, secret []byte, expires int64) string {
+	exp := strconv.FormatInt(expires, 10)
+	return path + "?expires=" + exp + "&signature=" + mediaSignature(path, exp, secret)
+}
+
+func verifyMediaSignature(path, expires, signature string, secret []byte) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	expected := mediaSignature(path, expires, secret)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"regexp"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+var mentionPattern = regexp.MustCompile(`(^|[^\w@])@([\w.-]*\w)`)
+
+// ListMentions swagger:route GET /user/mentions user listMentions
+//
+// Lists where the current user was mentioned, the latest mentions first.
+// Mentions in posts the user can not access anymore are left out.
+//
+// responses:
+//     200: []Mention
+//     500: description: internal server error
+func (s *Handler) ListMentions(w http.ResponseWriter, r *http.Request) (error, int) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	mentions, err := s.mentionRepo.SelectByUser(r.Context(), user)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	visible := []*model.Mention{}
+	accessible := map[int32]bool{}
+	for _, mention := range mentions {
+		if mention.GroupID.Valid {
+			if _, ok := accessible[mention.GroupID.Int32]; !ok {
+				accessible[mention.GroupID.Int32], err = s.groupAccessible(r.Context(), mention.GroupID, user)
+				if err != nil {
+					return err, http.StatusInternalServerError
+				}
+			}
+			if !accessible[mention.GroupID.Int32] {
+				continue
+			}
+		}
+		visible = append(visible, mention)
+	}
+	return WriteJsonResp(w, visible)
+}
+
+// recordMentions stores a mention for every user named in the text, who is
+// able to see the post. Unknown names and the author mentioning themself
+// are ignored.
+func (s *Handler) recordMentions(ctx context.Context, author *model.User, post *model.Post,
+	comment *model.Comment, text string) ([]*model.Mention, error) {
+	mentions := []*model.Mention{}
+	for _, name := range parseMentions(text) {
+		user, err := s.userRepo.GetByUsername(ctx, name)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return mentions, err
+		}
+		if user.ID == author.ID {
+			continue
+		}
+		visible, err := s.groupAccessible(ctx, post.GroupID, user)
+		if err != nil {
+			return mentions, err
+		}
+		if !visible {
+			continue
+		}
+		mention := &model.Mention{
+			UserID:   user.ID,
+			AuthorID: author.ID,
+			PostID:   post.ID,
+			GroupID:  post.GroupID,
+			Author:   author.Name,
+			PostUid:  post.UniqueID,
+		}
+		if comment != nil {
+			mention.CommentID = sql.NullInt32{Int32: int32(comment.ID), Valid: true}
+			mention.CommentUid = comment.UniqueID
+		}
+		if err := s.mentionRepo.Persist(ctx, mention); err != nil {
+			return mentions, err
+		}
+		mentions = append(mentions, mention)
+		e := s.postEvent(ctx, model.EventMention, author, post)
+		e.Data = mention
+		e.Recipients = []int{user.ID}
+		s.publish(e)
+	}
+	return mentions, nil
+}
+
+// parseMentions returns the distinct names mentioned with @name in the text
+func parseMentions(text string) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		if name := match[2]; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
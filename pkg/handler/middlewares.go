@@ -40,6 +40,23 @@ func keyMiddleware(h http.Handler) http.Handler {
 	})
 }
 
+// moveQueryCredentials passes the api key and token of the query on to
+// keyMiddleware and authenticationMiddleware, for clients like the
+// EventSource of browsers which can't set headers. They are removed from
+// the url, so they don't end up in the logs.
+func moveQueryCredentials(r *http.Request) {
+	query := r.URL.Query()
+	if key := query.Get("api_key"); key != "" && r.Header.Get("API_KEY") == "" {
+		r.Header.Set("API_KEY", key)
+	}
+	if token := query.Get("token"); token != "" && r.Header.Get("X-Auth-Token") == "" {
+		r.Header.Set("X-Auth-Token", token)
+	}
+	query.Del("api_key")
+	query.Del("token")
+	r.URL.RawQuery = query.Encode()
+}
+
 func authenticationMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenStr := r.Header.Get("X-Auth-Token")
@@ -107,7 +124,7 @@ func groupMiddleware(h http.Handler) http.Handler {
 
 func errorWrapper(f func(http.ResponseWriter, *http.Request) (error, int)) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ew := writer.
+		ew := writer.New(w)
 		config, ok := r.Context().Value("config").(*model.Config)
 		err, status := f(ew, r)
 		if err != nil {
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// The number of notifications returned if no limit is requested
+const defaultNotificationLimit = 50
+
+// ListNotifications swagger:route GET /notifications/list notifications listNotifications
+//
+// Returns the latest notifications of the current user
+//
+// responses:
+//     200: []Notification
+//     400: description: bad request
+//     500: description: internal server error
+func (s *Handler) ListNotifications(w http.ResponseWriter, r *http.Request) (error, int) {
+	limit := uint64(defaultNotificationLimit)
+	if count := r.URL.Query().Get("limit"); count != "" {
+		icount, err := strconv.ParseUint(count, 10, 64)
+		if err != nil || icount == 0 {
+			return logResponse(w, "invalid limit",
+				s.rlog.WithField("limit", count), http.StatusBadRequest)
+		}
+		limit = icount
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	unread := r.URL.Query().Get("unread") == "true"
+	notifications, err := s.notificationRepo.SelectByUser(r.Context(), user, unread, limit)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return WriteJsonResp(w, notifications)
+}
+
+// ReadNotification swagger:route GET /notifications/read notifications readNotification
+//
+// Marks a notification of the current user as read
+//
+// responses:
+//     200: description: successfully marked the notification
+//     400: description: bad request
+//     404: description: notification not found
+//     500: description: internal server error
+func (s *Handler) ReadNotification(w http.ResponseWriter, r *http.Request) (error, int) {
+	notificationUid := r.URL.Query().Get("notification_uid")
+	if notificationUid == "" {
+		return ErrMissingParam(w, "notification_uid", s.rlog)
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	notification, err := s.notificationRepo.GetByUid(r.Context(), notificationUid)
+	if err != nil && err != sql.ErrNoRows {
+		return err, http.StatusInternalServerError
+	}
+	if err == sql.ErrNoRows || notification.UserID != user.ID {
+		return logResponse(w, "notification not found",
+			s.rlog.WithField("notification_uid", notificationUid), http.StatusNotFound)
+	}
+	if err := s.notificationRepo.MarkRead(r.Context(), notification); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// ReadAllNotifications swagger:route GET /notifications/readall notifications readAllNotifications
+//
+// Marks all notifications of the current user as read
+//
+// responses:
+//     200: description: successfully marked the notifications
+//     500: description: internal server error
+func (s *Handler) ReadAllNotifications(w http.ResponseWriter, r *http.Request) (error, int) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err := s.notificationRepo.MarkAllRead(r.Context(), user); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// NotificationPreferences swagger:route GET /notifications/preferences notifications notificationPreferences
+//
+// Returns for every type of events whether the current user is notified
+// about it
+//
+// responses:
+//     200: []NotificationPreference
+//     500: description: internal server error
+func (s *Handler) NotificationPreferences(w http.ResponseWriter, r *http.Request) (error, int) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	stored, err := s.notificationRepo.SelectPreferences(r.Context(), user)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	enabled := map[string]bool{}
+	for _, preference := range stored {
+		enabled[preference.Type] = preference.Enabled
+	}
+	preferences := []*model.NotificationPreference{}
+	for _, kind := range model.EventTypes {
+		preference := &model.NotificationPreference{Type: kind, Enabled: true}
+		if v, ok := enabled[kind]; ok {
+			preference.Enabled = v
+		}
+		preferences = append(preferences, preference)
+	}
+	return WriteJsonResp(w, preferences)
+}
+
+// SetNotificationPreferences swagger:route POST /notifications/preferences notifications setNotificationPreferences
+//
+// Enables or disables notifications about types of events, types which are
+// not sent keep their setting
+//
+// responses:
+//     200: description: successfully stored the preferences
+//     400: description: bad request
+//     500: description: internal server error
+func (s *Handler) SetNotificationPreferences(w http.ResponseWriter, r *http.Request) (error, int) {
+	details := &model.SetNotificationPreferencesRequestBody{}
+	if err := json.NewDecoder(r.Body).Decode(&details.Preferences); err != nil {
+		return logResponse(w, "error encoding json",
+			s.rlog.WithFields(logrus.Fields{}).WithError(err), http.StatusBadRequest)
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	for _, preference := range details.Preferences {
+		if !isEventType(preference.Type) {
+			return logResponse(w, "unknown type of event",
+				s.rlog.WithField("type", preference.Type), http.StatusBadRequest)
+		}
+	}
+	for _, preference := range details.Preferences {
+		preference.UserID = user.ID
+		if err := s.notificationRepo.SetPreference(r.Context(), preference); err != nil {
+			return err, http.StatusInternalServerError
+		}
+	}
+	return nil, http.StatusOK
+}
+
+func isEventType(kind string) bool {
+	for _, v := range model.EventTypes {
+		if v == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterDevice swagger:route POST /notifications/devices notifications registerDevice
+//
+// Registers a device of the current user for push notifications
+//
+// responses:
+//     200: description: successfully registered the device
+//     400: description: bad request
+//     500: description: internal server error
+func (s *Handler) RegisterDevice(w http.ResponseWriter, r *http.Request) (error, int) {
+	device := &model.Device{}
+	if err := json.NewDecoder(r.Body).Decode(device); err != nil {
+		return logResponse(w, "error encoding json",
+			s.rlog.WithFields(logrus.Fields{}).WithError(err), http.StatusBadRequest)
+	}
+	if device.Token == "" {
+		return ErrMissingParam(w, "token", s.rlog)
+	}
+	if device.Platform != model.PlatformFcm && device.Platform != model.PlatformApns {
+		return logResponse(w, "unknown platform",
+			s.rlog.WithField("platform", device.Platform), http.StatusBadRequest)
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	device.UserID = user.ID
+	if err := s.deviceRepo.Persist(r.Context(), device); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// UnregisterDevice swagger:route GET /notifications/devices/remove notifications unregisterDevice
+//
+// Stops push notifications to a device of the current user
+//
+// responses:
+//     200: description: successfully removed the device
+//     400: description: bad request
+//     404: description: device not found
+//     500: description: internal server error
+func (s *Handler) UnregisterDevice(w http.ResponseWriter, r *http.Request) (error, int) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return ErrMissingParam(w, "token", s.rlog)
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	device, err := s.deviceRepo.GetByToken(r.Context(), token)
+	if err != nil && err != sql.ErrNoRows {
+		return err, http.StatusInternalServerError
+	}
+	if err == sql.ErrNoRows || device.UserID != user.ID {
+		return logResponse(w, "device not found", s.rlog.WithField("user", user.Name), http.StatusNotFound)
+	}
+	if err := s.deviceRepo.Remove(r.Context(), device); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
@@ -1,14 +1,15 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/sirupsen/logrus"
 
+	"gitlab.com/innoserver/pkg/method"
 	"gitlab.com/innoserver/pkg/model"
 )
 
@@ -16,14 +17,16 @@ import (
 //
 //   <p>Takes, processes and persist posts data
 //   A post file request model.
-//   Parameter "Method" is an integer and takes following numbers:</p>
-//     <ul><li>0: 101 Method</li>
-//     <li>1: Lotus Blossum</li>
-//     <li>2: Scamper</li>
-//     <li>3: Diese eine andere, ka...</li></ul>
-//   <p>Type is an integer and describes the file type:</p>
+//   Parameter "Method" is the id of a creativity method, the available
+//   methods and the structure they demand are listed at /methods.</p>
+//   <p>Type is an integer and describes the post type:</p>
 //     <ul><li>0: image</li>
-//     <li>1: video</li></ul>
+//     <li>1: video</li>
+//     <li>2: text, the text is sent as content without file</li>
+//     <li>3: audio</li>
+//     <li>4: sketch, the json encoded strokes are sent as content without file</li></ul>
+//   <p>Several files may be uploaded under the key "file", the first one
+//   is the cover of the post.</p>
 //
 //
 // consumes:
@@ -46,19 +49,77 @@ func (s *Handler) UploadPost(w http.ResponseWriter, r *http.Request) (error, int
 	s.log.WithFields(logrus.Fields{
 		"title": post.Title, "user": user.Name,
 	}).Infoln("trying to upload new post...")
+	return s.uploadPost(w, r, post, user)
+}
 
-	if post.Type < model.PostTypeImage || post.Type > model.PostTypeVideo {
+// uploadPost reads the content or files of an initialised post from the
+// request and persists it. The hooks are passed on to persistPost.
+func (s *Handler) uploadPost(w http.ResponseWriter, r *http.Request, post *model.Post, user *model.User,
+	hooks ...func(*model.Post) error) (error, int) {
+	if !isValidPostType(post.Type) {
 		return logResponse(w, "wrong type for post",
 			s.rlog.WithFields(logrus.Fields{
 				"type": post.Type,
 			}), http.StatusBadRequest)
 	}
+	if !isFilePostType(post.Type) {
+		if err := initContentPost(post, s, r); err != nil {
+			return logResponse(w, "upload failed", s.rlog.WithError(err), http.StatusBadRequest)
+		}
+		if err, status := s.checkQuota(w, r, user, post, post.Size); err != nil || status != http.StatusOK {
+			return err, status
+		}
+		return s.persistPost(w, r, post, user, hooks...)
+	}
 	maxSize := determineMaxPostSize(post, s)
-	path, _, err := s.UploadFile(r, maxSize, "file", post.Type)
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		return logResponse(w, "upload failed", s.rlog.WithError(err), http.StatusBadRequest)
+	}
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		return ErrMissingParam(w, "file", s.rlog)
+	}
+	if s.config.MaxPostMedia > 0 && len(files) > s.config.MaxPostMedia {
+		return logResponse(w, "too many files for post",
+			s.rlog.WithFields(logrus.Fields{
+				"files":   len(files),
+				"allowed": s.config.MaxPostMedia,
+			}), http.StatusBadRequest)
+	}
+	for _, header := range files {
+		fType := mediaTypeOfContentType(header.Header.Get("Content-Type"), post.Type)
+		if header.Size > determineMaxFileSize(fType, s) {
+			return logResponse(w, "file exceeds the maximum size",
+				s.rlog.WithFields(logrus.Fields{
+					"file": header.Filename,
+					"size": header.Size,
+				}), http.StatusRequestEntityTooLarge)
+		}
+		post.Size += header.Size
+	}
+	if err, status := s.checkQuota(w, r, user, post, post.Size); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	var err error
+	post.Media, err = s.UploadFiles(r, "file", post.Type)
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
-	post.Path = path
+	post.Path = post.Media[0].Path
+	post.Type = post.Media[0].Type
+	return s.persistPost(w, r, post, user, hooks...)
+}
+
+// persistPost verifies the structure of the post, assigns it to the user and
+// writes its unique id into the response. Every upload routine finishes
+// through this function. The hooks run after the post was stored, if one
+// of them fails the post is removed again.
+func (s *Handler) persistPost(w http.ResponseWriter, r *http.Request, post *model.Post,
+	user *model.User, hooks ...func(*model.Post) error) (error, int) {
+	if err, status := s.checkPostStructure(w, r, post); err != nil || status != http.StatusOK {
+		s.releasePostFiles(r.Context(), []*model.Post{post})
+		return err, status
+	}
 	post.UserID = user.ID
 	uid, err := generateUid(s.postRepo, r)
 	if err != nil || uid == "" {
@@ -66,11 +127,30 @@ func (s *Handler) UploadPost(w http.ResponseWriter, r *http.Request) (error, int
 	}
 	post.UniqueID = uid
 	if err := s.postRepo.Persist(r.Context(), post); err != nil {
+		s.releasePostFiles(r.Context(), []*model.Post{post})
+		return err, http.StatusInternalServerError
+	}
+	err = s.postRepo.AddMedia(r.Context(), post, post.Media)
+	if err == nil && len(post.Options) > 0 {
+		err = s.postRepo.AddOptions(r.Context(), post, post.Options)
+	}
+	for i := 0; err == nil && i < len(hooks); i++ {
+		err = hooks[i](post)
+	}
+	if err != nil {
+		if err := s.postRepo.RemovePost(r.Context(), post); err != nil {
+			s.log.WithField("post", post.UniqueID).WithError(err).Errorln("removing incomplete post failed")
+		}
+		s.releasePostFiles(r.Context(), []*model.Post{post})
 		return err, http.StatusInternalServerError
 	}
 	s.log.WithFields(logrus.Fields{
 		"title": post.Title, "user": user.Name,
 	}).Infoln("post uploaded successfully")
+	if _, err := s.recordMentions(r.Context(), user, post, nil, post.Title); err != nil {
+		s.log.WithField("post", post.UniqueID).WithError(err).Errorln("recording mentions failed")
+	}
+	s.publishPostCreated(r.Context(), user, post)
 	return WriteJsonResp(w, &model.UidResponse{UniqueID: post.UniqueID})
 }
 
@@ -81,19 +161,18 @@ func (s *Handler) UploadPost(w http.ResponseWriter, r *http.Request) (error, int
 // responses:
 //     200: description: postBody
 //     400: description: bad request
+//     401: description: user is not in the group of the post
+//     404: description: post not found
 //     500: description: server internal error
 func (s *Handler) GetPost(w http.ResponseWriter, r *http.Request) (error, int) {
-	uid := r.URL.Query().Get("uid")
-	if uid == "" {
-		return ErrMissingParam(w, "uid", s.rlog)
-	}
-	post, err := s.postRepo.GetByUid(r.Context(), uid)
-	if err != nil {
-		return err, http.StatusInternalServerError
+	post, err, status := s.accessiblePostOf(w, r, "uid")
+	if post == nil {
+		return err, status
 	}
 	s.log.WithFields(logrus.Fields{
 		"title": post.Title, "uid": post.UniqueID,
 	}).Infoln("fetching post")
+	s.preparePosts(post)
 	return WriteJsonResp(w, post)
 }
 
@@ -102,24 +181,23 @@ func (s *Handler) GetPost(w http.ResponseWriter, r *http.Request) (error, int) {
 // Fetch all subposts of a specific parent post
 // responses:
 //    200: description: successfully returned a list of subposts
+//    401: description: user is not in the group of the post
+//    404: description: post not found
 func (s *Handler) GetChildren(w http.ResponseWriter, r *http.Request) (error, int) {
-	parent := r.URL.Query().Get("parent_uid")
-	if parent == "" {
-		return errors.New("parameter parent_uid missing in request"), http.StatusBadRequest
-	}
-	parentPost, err := s.postRepo.GetByUid(r.Context(), parent)
-	if err != nil {
-		return err, http.StatusInternalServerError
+	parentPost, err, status := s.accessiblePostOf(w, r, "parent_uid")
+	if parentPost == nil {
+		return err, status
 	}
 	posts, err := s.postRepo.SelectByParent(r.Context(), parentPost)
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
 	s.log.WithFields(logrus.Fields{
-		"parent":       parent,
+		"parent":       parentPost.UniqueID,
 		"parent_title": parentPost.Title,
 		"children":     len(posts),
 	}).Infoln("fetching child posts")
+	s.preparePosts(posts...)
 	return WriteJsonResp(w, posts)
 }
 
@@ -159,12 +237,14 @@ func (s *Handler) FetchLatestPosts(w http.ResponseWriter, r *http.Request) (erro
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
+	s.preparePosts(posts...)
 	return WriteJsonResp(w, posts)
 }
 
 // SetOptions swagger:route POST /post/setoptions post setOptions
 //
-// Set a List of options for a post
+// Set a List of options for a post, replacing its current options. The
+// options are validated against the option schema of the posts method.
 //
 // responses:
 //    200: description: successfully updated posts options
@@ -189,13 +269,17 @@ func (s *Handler) SetOptions(w http.ResponseWriter, r *http.Request) (error, int
 	if user.ID != post.UserID {
 		return err, http.StatusUnauthorized
 	}
-	for _, v := range options {
+	if err, status := s.checkPostOptions(w, post, options); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	for _, v := range post.Options {
 		v.PostUid = post_uid
 	}
-	err = s.postRepo.SetOptions(r.Context(), post, options)
+	err = s.postRepo.SetOptions(r.Context(), post, post.Options)
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
+	s.publishOptionsChanged(r.Context(), user, post)
 	return nil, http.StatusOK
 }
 
@@ -238,12 +322,14 @@ func (s *Handler) Find(w http.ResponseWriter, r *http.Request) (error, int) {
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
+	s.preparePosts(posts...)
 	return WriteJsonResp(w, posts)
 }
 
 // AddOptions swagger:route POST /post/addoptions post AddOptions
 //
-// Set a List of options for a post
+// Add a List of options to a post. The options are validated against the
+// option schema of the posts method.
 //
 // responses:
 //    200: description: successfully added posts options
@@ -265,6 +351,26 @@ func (s *Handler) AddOptions(w http.ResponseWriter, r *http.Request) (error, int
 	if user.ID != post.UserID {
 		return err, http.StatusUnauthorized
 	}
+	keys := map[string]bool{}
+	for _, option := range post.Options {
+		keys[option.Key] = true
+	}
+	for _, option := range options {
+		if keys[option.Key] {
+			return logResponse(w, "option is already set, use upsertoptions to change it",
+				s.rlog.WithFields(logrus.Fields{
+					"post_uid": post_uid,
+					"key":      option.Key,
+				}), http.StatusConflict)
+		}
+		keys[option.Key] = true
+	}
+	previous := post.Options
+	combined := append(append([]*model.Option{}, previous...), options...)
+	if err, status := s.checkPostOptions(w, post, combined); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	options = changedOptions(previous, post.Options)
 	for _, v := range options {
 		v.PostUid = post_uid
 	}
@@ -272,6 +378,7 @@ func (s *Handler) AddOptions(w http.ResponseWriter, r *http.Request) (error, int
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
+	s.publishOptionsChanged(r.Context(), user, post)
 	return nil, http.StatusOK
 }
 
@@ -295,6 +402,110 @@ func (s *Handler) RemoveOptions(w http.ResponseWriter, r *http.Request) (error,
 	if user.ID != post.UserID {
 		return err, http.StatusUnauthorized
 	}
+	if err, status := s.checkPostOptions(w, post, nil); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	err = s.postRepo.RemoveOptions(r.Context(), post)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	s.publishOptionsChanged(r.Context(), user, post)
+	return nil, http.StatusOK
+}
+
+// UpsertOptions swagger:route POST /post/upsertoptions post upsertOptions
+//
+// Add options to a post or update the values of existing keys, all other
+// options of the post stay untouched
+//
+// responses:
+//    200: description: successfully updated posts options
+//    400: description: options don't match the schema of the method
+func (s *Handler) UpsertOptions(w http.ResponseWriter, r *http.Request) (error, int) {
+	postUid := r.URL.Query().Get("post_uid")
+	var options []*model.Option
+	err := json.NewDecoder(r.Body).Decode(&options)
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusUnauthorized
+	}
+	if postUid == "" {
+		return ErrMissingParam(w, "post_uid", s.rlog)
+	}
+	post, err := s.postRepo.GetByUid(r.Context(), postUid)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if user.ID != post.UserID {
+		return err, http.StatusUnauthorized
+	}
+	updated := map[string]bool{}
+	for _, option := range options {
+		updated[option.Key] = true
+	}
+	merged := []*model.Option{}
+	for _, option := range post.Options {
+		if !updated[option.Key] {
+			merged = append(merged, option)
+		}
+	}
+	previous := post.Options
+	if err, status := s.checkPostOptions(w, post, append(merged, options...)); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	options = changedOptions(previous, post.Options)
+	err = s.postRepo.UpsertOptions(r.Context(), post, options)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	s.publishOptionsChanged(r.Context(), user, post)
+	return nil, http.StatusOK
+}
+
+// RemoveOption swagger:route GET /post/removeoption post removeOption
+//
+// Remove a single option from a post
+//
+// responses:
+//    200: description: successfully removed the option
+//    400: description: the option is required by the method
+func (s *Handler) RemoveOption(w http.ResponseWriter, r *http.Request) (error, int) {
+	postUid := r.URL.Query().Get("uid")
+	key := r.URL.Query().Get("key")
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusUnauthorized
+	}
+	if postUid == "" {
+		return ErrMissingParam(w, "uid", s.rlog)
+	}
+	if key == "" {
+		return ErrMissingParam(w, "key", s.rlog)
+	}
+	post, err := s.postRepo.GetByUid(r.Context(), postUid)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if user.ID != post.UserID {
+		return err, http.StatusUnauthorized
+	}
+	remaining := []*model.Option{}
+	for _, option := range post.Options {
+		if option.Key != key {
+			remaining = append(remaining, option)
+		}
+	}
+	if err, status := s.checkPostOptions(w, post, remaining); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	err = s.postRepo.RemoveOption(r.Context(), post, key)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	s.publishOptionsChanged(r.Context(), user, post)
 	return nil, http.StatusOK
 }
 
@@ -318,9 +529,191 @@ func (s *Handler) RemovePost(w http.ResponseWriter, r *http.Request) (error, int
 	if user.ID != post.UserID {
 		return err, http.StatusUnauthorized
 	}
+	removed, err := s.collectPostTree(r.Context(), post)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
 	err = s.postRepo.RemovePost(r.Context(), post)
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
+	s.releasePostFiles(r.Context(), removed)
+	s.publish(s.postEvent(r.Context(), model.EventPostRemoved, user, post))
 	return nil, http.StatusOK
 }
+
+// collectPostTree returns the post and all of its descendants, which are
+// removed together with the post
+func (s *Handler) collectPostTree(ctx context.Context, post *model.Post) ([]*model.Post, error) {
+	posts := []*model.Post{post}
+	children, err := s.postRepo.SelectByParent(ctx, post)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		subtree, err := s.collectPostTree(ctx, child)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, subtree...)
+	}
+	return posts, nil
+}
+
+// releasePostFiles releases the stored files of already removed posts
+func (s *Handler) releasePostFiles(ctx context.Context, posts []*model.Post) {
+	for _, post := range posts {
+		media := post.Media
+		if len(media) == 0 && post.Path != "" {
+			media = []*model.PostMedia{{Path: post.Path, Type: post.Type}}
+		}
+		s.releaseMedia(ctx, media)
+	}
+}
+
+func (s *Handler) releaseMedia(ctx context.Context, media []*model.PostMedia) {
+	for _, m := range media {
+		if err := s.releaseFile(ctx, m.Type, m.Path); err != nil {
+			s.log.WithField("file", m.Path).WithError(err).Errorln("releasing file failed")
+		}
+	}
+}
+
+// accessiblePost fetches the post of the request and ensures the user may
+// access it. If the returned post is nil, the error and status have to be
+// returned by the calling handler.
+func (s *Handler) accessiblePost(w http.ResponseWriter, r *http.Request) (*model.Post, error, int) {
+	return s.accessiblePostOf(w, r, "post_uid")
+}
+
+// accessiblePostOf works like accessiblePost for the post whose uid is
+// passed in the query parameter param
+func (s *Handler) accessiblePostOf(w http.ResponseWriter, r *http.Request, param string) (*model.Post, error, int) {
+	postUid := r.URL.Query().Get(param)
+	if postUid == "" {
+		err, status := ErrMissingParam(w, param, s.rlog)
+		return nil, err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	post, err := s.postRepo.GetByUid(r.Context(), postUid)
+	if err == sql.ErrNoRows {
+		err, status := logResponse(w, "post not found",
+			s.rlog.WithField(param, postUid), http.StatusNotFound)
+		return nil, err, status
+	}
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	if err, status := s.checkGroupAccess(w, r, post.GroupID, user); err != nil || status != http.StatusOK {
+		return nil, err, status
+	}
+	return post, nil, http.StatusOK
+}
+
+// postAncestors returns the parent chain of a post, beginning with its
+// parent and ending with the root post
+func (s *Handler) postAncestors(ctx context.Context, post *model.Post) ([]*model.Post, error) {
+	ancestors := []*model.Post{}
+	parentID := post.ParentID
+	for parentID.Valid {
+		parent, err := s.postRepo.GetByID(ctx, int(parentID.Int32))
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, parent)
+		parentID = parent.ParentID
+	}
+	return ancestors, nil
+}
+
+// checkPostStructure verifies that a new post fits into the structure the
+// creativity method of its root post demands. Child posts take the method of
+// their root post, whatever method the client sent.
+func (s *Handler) checkPostStructure(w http.ResponseWriter, r *http.Request, post *model.Post) (error, int) {
+	if !post.ParentID.Valid {
+		if _, ok := method.Get(post.Method); !ok {
+			return logResponse(w, "unknown creativity method",
+				s.rlog.WithField("method", post.Method), http.StatusBadRequest)
+		}
+		if post.Prompt != "" {
+			return logResponse(w, "only answers take a prompt",
+				s.rlog.WithField("prompt", post.Prompt), http.StatusBadRequest)
+		}
+		return s.checkPostOptions(w, post, post.Options)
+	}
+	ancestors, err := s.postAncestors(r.Context(), post)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	root := ancestors[len(ancestors)-1]
+	post.Method = root.Method
+	if err, status := s.checkPostOptions(w, post, post.Options); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	m, ok := method.Get(root.Method)
+	if !ok {
+		// posts of methods which were removed from the registry stay
+		// unrestricted, besides prompts which no known method offers
+		if post.Prompt != "" {
+			return logResponse(w, "method has no prompts",
+				s.rlog.WithField("prompt", post.Prompt), http.StatusBadRequest)
+		}
+		return nil, http.StatusOK
+	}
+	return s.methodResponse(w, m.CheckPost(r.Context(), s.postRepo, post, ancestors))
+}
+
+// checkPostOptions validates the complete option set of a post against the
+// schema of its method and normalises the options of the post. Options of
+// methods missing from the registry are taken as they are.
+func (s *Handler) checkPostOptions(w http.ResponseWriter, post *model.Post, options []*model.Option) (error, int) {
+	keys := map[string]bool{}
+	for _, option := range options {
+		if keys[option.Key] {
+			return logResponse(w, "option is set twice",
+				s.rlog.WithField("key", option.Key), http.StatusBadRequest)
+		}
+		keys[option.Key] = true
+	}
+	m, ok := method.Get(post.Method)
+	if !ok {
+		post.Options = options
+		return nil, http.StatusOK
+	}
+	checked, err := m.CheckOptions(options)
+	if err != nil {
+		return s.methodResponse(w, err)
+	}
+	post.Options = checked
+	return nil, http.StatusOK
+}
+
+// changedOptions returns the checked options, whose key is new or whose
+// value differs from the previous options of the post
+func changedOptions(previous, checked []*model.Option) []*model.Option {
+	values := map[string]string{}
+	for _, option := range previous {
+		values[option.Key] = option.Value
+	}
+	changed := []*model.Option{}
+	for _, option := range checked {
+		if value, ok := values[option.Key]; !ok || value != option.Value {
+			changed = append(changed, option)
+		}
+	}
+	return changed
+}
+
+// methodResponse writes the response for failed method validations
+func (s *Handler) methodResponse(w http.ResponseWriter, err error) (error, int) {
+	if err == nil {
+		return nil, http.StatusOK
+	}
+	if e, ok := err.(*method.Error); ok {
+		return logResponse(w, e.Message, s.rlog.WithError(err), e.Status)
+	}
+	return err, http.StatusInternalServerError
+}
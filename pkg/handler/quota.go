@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+func (s *Handler) userUsage(ctx context.Context, user *model.User) (*model.StorageUsage, error) {
+	used, err := s.postRepo.SumSizeByUser(ctx, user)
+	return &model.StorageUsage{Used: used, Quota: s.config.UserQuota}, err
+}
+
+func (s *Handler) groupUsage(ctx context.Context, group *model.Group) (*model.StorageUsage, error) {
+	used, err := s.postRepo.SumSizeByGroup(ctx, group)
+	quota := s.config.GroupQuota
+	if override, ok := s.config.GroupQuotas[group.UniqueID]; ok {
+		quota = override
+	}
+	return &model.StorageUsage{Used: used, Quota: quota}, err
+}
+
+func exceedsQuota(usage *model.StorageUsage, size int64) bool {
+	return usage.Quota > 0 && usage.Used+size > usage.Quota
+}
+
+// checkQuota verifies that storing size additional bytes for the post
+// exceeds neither the quota of the user nor the one of the posts group
+func (s *Handler) checkQuota(w http.ResponseWriter, r *http.Request, user *model.User,
+	post *model.Post, size int64) (error, int) {
+	usage, err := s.userUsage(r.Context(), user)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if exceedsQuota(usage, size) {
+		return logResponse(w, "upload exceeds the storage quota of the user",
+			s.rlog.WithFields(logrus.Fields{
+				"user":  user.Name,
+				"used":  usage.Used,
+				"quota": usage.Quota,
+				"size":  size,
+			}), http.StatusRequestEntityTooLarge)
+	}
+	if !post.GroupID.Valid {
+		return nil, http.StatusOK
+	}
+	group, err := s.groupRepo.GetByID(r.Context(), int(post.GroupID.Int32))
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	usage, err = s.groupUsage(r.Context(), group)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if exceedsQuota(usage, size) {
+		return logResponse(w, "upload exceeds the storage quota of the group",
+			s.rlog.WithFields(logrus.Fields{
+				"group": group.Title,
+				"used":  usage.Used,
+				"quota": usage.Quota,
+				"size":  size,
+			}), http.StatusRequestEntityTooLarge)
+	}
+	return nil, http.StatusOK
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// React swagger:route GET /post/react post react
+//
+// Adds a reaction of the current user to a post. Every kind of reaction
+// counts once per user and post.
+//
+// responses:
+//     200: description: successfully reacted
+//     400: description: bad request
+//     401: description: user is not in the group of the post
+//     404: description: post not found
+//     500: description: internal server error
+func (s *Handler) React(w http.ResponseWriter, r *http.Request) (error, int) {
+	reaction, err, status := s.requestedReaction(w, r)
+	if reaction == nil {
+		return err, status
+	}
+	if err := s.reactionRepo.Add(r.Context(), reaction); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// Unreact swagger:route GET /post/unreact post unreact
+//
+// Removes a reaction of the current user from a post
+//
+// responses:
+//     200: description: successfully removed reaction
+//     400: description: bad request
+//     401: description: user is not in the group of the post
+//     404: description: post not found
+//     500: description: internal server error
+func (s *Handler) Unreact(w http.ResponseWriter, r *http.Request) (error, int) {
+	reaction, err, status := s.requestedReaction(w, r)
+	if reaction == nil {
+		return err, status
+	}
+	if err := s.reactionRepo.Remove(r.Context(), reaction); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// ListReactions swagger:route GET /post/reactions post listReactions
+//
+// Lists who reacted on a post, oldest reactions first
+//
+// responses:
+//     200: []Reaction
+//     400: description: bad request
+//     401: description: user is not in the group of the post
+//     404: description: post not found
+//     500: description: internal server error
+func (s *Handler) ListReactions(w http.ResponseWriter, r *http.Request) (error, int) {
+	post, err, status := s.accessiblePost(w, r)
+	if post == nil {
+		return err, status
+	}
+	reactions, err := s.reactionRepo.SelectByPost(r.Context(), post)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return WriteJsonResp(w, reactions)
+}
+
+// requestedReaction builds the reaction of the current user described by
+// the request. If the returned reaction is nil, the error and status have
+// to be returned by the calling handler.
+func (s *Handler) requestedReaction(w http.ResponseWriter, r *http.Request) (*model.Reaction, error, int) {
+	kind := r.URL.Query().Get("kind")
+	if kind == "" {
+		err, status := ErrMissingParam(w, "kind", s.rlog)
+		return nil, err, status
+	}
+	if !s.isReactionKind(kind) {
+		err, status := logResponse(w, "unknown kind of reaction",
+			s.rlog.WithFields(logrus.Fields{
+				"kind":    kind,
+				"allowed": s.config.Reactions,
+			}), http.StatusBadRequest)
+		return nil, err, status
+	}
+	post, err, status := s.accessiblePost(w, r)
+	if post == nil {
+		return nil, err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	return &model.Reaction{PostID: post.ID, UserID: user.ID, Kind: kind}, nil, http.StatusOK
+}
+
+func (s *Handler) isReactionKind(kind string) bool {
+	for _, k := range s.config.Reactions {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/method"
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// ScamperSession swagger:route GET /post/scamper post scamperSession
+//
+// Returns a scamper session with the answers to each of the seven prompts
+// and the prompts which were skipped so far
+//
+// responses:
+//     200: ScamperSession
+//     400: description: bad request
+//     401: description: user is not in the group of the post
+//     404: description: post not found
+//     500: description: server internal error
+func (s *Handler) ScamperSession(w http.ResponseWriter, r *http.Request) (error, int) {
+	root, err, status := s.accessiblePostOf(w, r, "uid")
+	if root == nil {
+		return err, status
+	}
+	if root.ParentID.Valid || root.Method != method.Scamper {
+		return logResponse(w, "post is no scamper root",
+			s.rlog.WithFields(logrus.Fields{
+				"uid":    root.UniqueID,
+				"method": root.Method,
+			}), http.StatusBadRequest)
+	}
+	children, err := s.postRepo.SelectByParent(r.Context(), root)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	s.preparePosts(root)
+	s.preparePosts(children...)
+	session := &model.ScamperSession{Root: root, Skipped: []string{}}
+	for _, prompt := range model.ScamperPrompts {
+		slot := &model.ScamperSlot{ScamperPrompt: *prompt, Posts: []*model.Post{}}
+		for _, child := range children {
+			if child.Prompt == prompt.Letter {
+				slot.Posts = append(slot.Posts, child)
+			}
+		}
+		if len(slot.Posts) == 0 {
+			session.Skipped = append(session.Skipped, prompt.Letter)
+		}
+		session.Slots = append(session.Slots, slot)
+	}
+	answered := len(model.ScamperPrompts) - len(session.Skipped)
+	session.Coverage = float64(answered) / float64(len(model.ScamperPrompts))
+	return WriteJsonResp(w, session)
+}
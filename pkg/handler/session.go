@@ -0,0 +1,497 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/method"
+	"gitlab.com/innoserver/pkg/model"
+	"gitlab.com/innoserver/pkg/session"
+)
+
+const defaultRoundDuration = 300
+
+// CreateSession swagger:route POST /session/create session createSession
+//
+// Creates a session on a root post of a round based method, e.g. 6-3-5.
+// The creating user facilitates the session and takes the first seat.
+//
+// responses:
+//     200: uidResponse
+//     400: description: bad request
+//     401: description: user is not in the group of the post
+//     500: description: internal server error
+func (s *Handler) CreateSession(w http.ResponseWriter, r *http.Request) (error, int) {
+	details := &model.CreateSessionRequestBody{}
+	if err := json.NewDecoder(r.Body).Decode(&details.Info); err != nil {
+		return logResponse(w, "error encoding json",
+			s.rlog.WithFields(logrus.Fields{}).WithError(err), http.StatusBadRequest)
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if details.Info.PostUid == "" {
+		return ErrMissingParam(w, "post_uid", s.rlog)
+	}
+	topic, err := s.postRepo.GetByUid(r.Context(), details.Info.PostUid)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	m, ok := method.Get(topic.Method)
+	if topic.ParentID.Valid || !ok || !m.Rounds {
+		return logResponse(w, "sessions can only be held on root posts of round based methods",
+			s.rlog.WithFields(logrus.Fields{
+				"post_uid": topic.UniqueID,
+				"method":   topic.Method,
+			}), http.StatusBadRequest)
+	}
+	if err, status := s.checkGroupAccess(w, r, topic.GroupID, user); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	sess := &model.Session{
+		PostID:        topic.ID,
+		FacilitatorID: user.ID,
+		GroupID:       topic.GroupID,
+		Method:        topic.Method,
+		RoundDuration: details.Info.RoundDuration,
+		Rounds:        details.Info.Rounds,
+		IdeasPerRound: details.Info.IdeasPerRound,
+	}
+	if sess.RoundDuration <= 0 {
+		sess.RoundDuration = s.config.SessionRoundDuration
+	}
+	if sess.RoundDuration <= 0 {
+		sess.RoundDuration = defaultRoundDuration
+	}
+	if sess.Rounds < 0 {
+		sess.Rounds = 0
+	}
+	if sess.IdeasPerRound <= 0 {
+		sess.IdeasPerRound = m.IdeasPerRound
+	}
+	if sess.IdeasPerRound <= 0 {
+		sess.IdeasPerRound = 1
+	}
+	sess.UniqueID, err = generateUid(s.sessionRepo, r)
+	if err != nil || sess.UniqueID == "" {
+		return err, http.StatusInternalServerError
+	}
+	if err := s.sessionRepo.Persist(r.Context(), sess); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	sess, err = s.sessionRepo.GetByUid(r.Context(), sess.UniqueID)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	_, err = s.sessionRepo.AddParticipant(r.Context(), &model.SessionParticipant{
+		SessionID: sess.ID,
+		UserID:    user.ID,
+	})
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	s.log.WithFields(logrus.Fields{
+		"session": sess.UniqueID, "topic": topic.UniqueID, "user": user.Name,
+	}).Infoln("session created")
+	return WriteJsonResp(w, &model.UidResponse{UniqueID: sess.UniqueID})
+}
+
+// JoinSession swagger:route GET /session/join session joinSession
+//
+// Takes the next free seat of a session which wasn't started yet
+//
+// responses:
+//     200: description: successfully joined the session
+//     400: description: bad request
+//     401: description: user is not in the group of the session
+//     404: description: session not found
+//     409: description: session was already started
+//     500: description: internal server error
+func (s *Handler) JoinSession(w http.ResponseWriter, r *http.Request) (error, int) {
+	sess, err, status := s.currentSession(w, r)
+	if sess == nil {
+		return err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if sess.State != model.SessionOpen {
+		return logResponse(w, "session was already started",
+			s.rlog.WithField("session", sess.UniqueID), http.StatusConflict)
+	}
+	if err, status := s.checkGroupAccess(w, r, sess.GroupID, user); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	participants, err := s.sessionRepo.SelectParticipants(r.Context(), sess)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if sessionSeat(participants, user) >= 0 {
+		return logResponse(w, "user already takes part in the session",
+			s.rlog.WithFields(logrus.Fields{
+				"session": sess.UniqueID,
+				"user":    user.Name,
+			}), http.StatusBadRequest)
+	}
+	joined, err := s.sessionRepo.AddParticipant(r.Context(), &model.SessionParticipant{
+		SessionID: sess.ID,
+		UserID:    user.ID,
+	})
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if !joined {
+		return logResponse(w, "session was already started",
+			s.rlog.WithField("session", sess.UniqueID), http.StatusConflict)
+	}
+	return nil, http.StatusOK
+}
+
+// StartSession swagger:route GET /session/start session startSession
+//
+// Starts the first round of a session, only the facilitator may start it.
+// Sessions need at least two participants.
+//
+// responses:
+//     200: Session
+//     400: description: bad request
+//     401: description: user is not the facilitator
+//     404: description: session not found
+//     409: description: session was already started
+//     500: description: internal server error
+func (s *Handler) StartSession(w http.ResponseWriter, r *http.Request) (error, int) {
+	sess, err, status := s.facilitatedSession(w, r)
+	if sess == nil {
+		return err, status
+	}
+	if sess.State != model.SessionOpen {
+		return logResponse(w, "session was already started",
+			s.rlog.WithField("session", sess.UniqueID), http.StatusConflict)
+	}
+	participants, err := s.sessionRepo.SelectParticipants(r.Context(), sess)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if len(participants) < 2 {
+		return logResponse(w, "sessions need at least two participants",
+			s.rlog.WithField("session", sess.UniqueID), http.StatusBadRequest)
+	}
+	session.Start(sess, len(participants), time.Now())
+	updated, err := s.sessionRepo.UpdateState(r.Context(), sess, model.SessionOpen, 0)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if !updated {
+		return logResponse(w, "session was already started",
+			s.rlog.WithField("session", sess.UniqueID), http.StatusConflict)
+	}
+	s.log.WithFields(logrus.Fields{
+		"session": sess.UniqueID, "participants": len(participants), "rounds": sess.Rounds,
+	}).Infoln("session started")
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	e := s.groupEvent(r.Context(), model.EventSessionStarted, user, sess.GroupID)
+	e.Data = sess.UniqueID
+	for _, participant := range participants {
+		e.Recipients = append(e.Recipients, participant.UserID)
+	}
+	s.publish(e)
+	return WriteJsonResp(w, sess)
+}
+
+// CloseSession swagger:route GET /session/close session closeSession
+//
+// Ends a session before its last round is over, only the facilitator may
+// close it
+//
+// responses:
+//     200: Session
+//     401: description: user is not the facilitator
+//     404: description: session not found
+//     409: description: session was changed meanwhile
+//     500: description: internal server error
+func (s *Handler) CloseSession(w http.ResponseWriter, r *http.Request) (error, int) {
+	sess, err, status := s.facilitatedSession(w, r)
+	if sess == nil {
+		return err, status
+	}
+	state, round := sess.State, sess.CurrentRound
+	session.Close(sess)
+	updated, err := s.sessionRepo.UpdateState(r.Context(), sess, state, round)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if !updated {
+		return logResponse(w, "session was changed meanwhile",
+			s.rlog.WithField("session", sess.UniqueID), http.StatusConflict)
+	}
+	return WriteJsonResp(w, sess)
+}
+
+// SessionInfo swagger:route GET /session/info session sessionInfo
+//
+// Returns the state of a session, its participants and contributions. For
+// participants of a running session the assignment of the current round
+// is included.
+//
+// responses:
+//     200: SessionInfo
+//     400: description: bad request
+//     401: description: user is not in the group of the session
+//     404: description: session not found
+//     500: description: internal server error
+func (s *Handler) SessionInfo(w http.ResponseWriter, r *http.Request) (error, int) {
+	sess, err, status := s.currentSession(w, r)
+	if sess == nil {
+		return err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err, status := s.checkGroupAccess(w, r, sess.GroupID, user); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	topic, err := s.postRepo.GetByID(r.Context(), sess.PostID)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	participants, err := s.sessionRepo.SelectParticipants(r.Context(), sess)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	contributions, err := s.sessionRepo.SelectContributions(r.Context(), sess)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	s.preparePosts(topic)
+	return WriteJsonResp(w, &model.SessionInfo{
+		Session:       sess,
+		Topic:         topic,
+		Participants:  participants,
+		Contributions: contributions,
+		Assignment:    sessionAssignment(sess, topic, participants, contributions, user),
+	})
+}
+
+// ContributeToSession swagger:route POST /session/contribute session contributeToSession
+//
+// Uploads an idea for the current round. The post is created below the
+// idea of the previous round on the same sheet, in the first round below
+// the topic post. Posts are accepted until the round deadline only.
+//
+// consumes:
+//     multipart/form-data
+//
+// responses:
+//     200: uidResponse
+//     400: description: bad request
+//     401: description: user doesn't take part in the session
+//     404: description: session not found
+//     409: description: session not running or idea already contributed
+//     500: description: internal server error
+func (s *Handler) ContributeToSession(w http.ResponseWriter, r *http.Request) (error, int) {
+	sess, err, status := s.currentSession(w, r)
+	if sess == nil {
+		return err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if sess.State != model.SessionRunning {
+		return logResponse(w, "session is not running",
+			s.rlog.WithFields(logrus.Fields{
+				"session": sess.UniqueID,
+				"state":   sess.State,
+			}), http.StatusConflict)
+	}
+	participants, err := s.sessionRepo.SelectParticipants(r.Context(), sess)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	seat := sessionSeat(participants, user)
+	if seat < 0 {
+		return logResponse(w, "user doesn't take part in the session",
+			s.rlog.WithFields(logrus.Fields{
+				"session": sess.UniqueID,
+				"user":    user.Name,
+			}), http.StatusUnauthorized)
+	}
+	contributions, err := s.sessionRepo.SelectContributions(r.Context(), sess)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	sheet := session.Sheet(seat, sess.CurrentRound, len(participants))
+	idea := 0
+	if value := r.FormValue("idea"); value != "" {
+		if idea, err = strconv.Atoi(value); err != nil {
+			return ErrMissingParam(w, "idea", s.rlog)
+		}
+	} else {
+		for i := 1; i <= sess.IdeasPerRound && idea == 0; i++ {
+			if sessionContribution(contributions, sess.CurrentRound, sheet, i) == nil {
+				idea = i
+			}
+		}
+	}
+	if idea < 1 || idea > sess.IdeasPerRound || sessionContribution(contributions, sess.CurrentRound, sheet, idea) != nil {
+		return logResponse(w, "idea is not available in this round",
+			s.rlog.WithFields(logrus.Fields{
+				"session": sess.UniqueID,
+				"round":   sess.CurrentRound,
+				"idea":    idea,
+			}), http.StatusConflict)
+	}
+	post := &model.Post{
+		Title:    r.FormValue("title"),
+		GroupID:  sess.GroupID,
+		Method:   sess.Method,
+		ParentID: sql.NullInt32{Int32: int32(sess.PostID), Valid: true},
+	}
+	if parent := sheetParent(contributions, sess.CurrentRound, sheet, idea); parent != nil {
+		post.ParentID.Int32 = int32(parent.PostID)
+	}
+	if post.Type, err = strconv.Atoi(r.FormValue("type")); err != nil {
+		return ErrMissingParam(w, "type", s.rlog)
+	}
+	return s.uploadPost(w, r, post, user, func(post *model.Post) error {
+		return s.sessionRepo.AddContribution(r.Context(), &model.SessionContribution{
+			SessionID: sess.ID,
+			PostID:    post.ID,
+			UserID:    user.ID,
+			Round:     sess.CurrentRound,
+			Sheet:     sheet,
+			Idea:      idea,
+		})
+	})
+}
+
+// currentSession fetches the session of the request and advances it, if its
+// round deadline passed. If the returned session is nil, the error and status
+// have to be returned by the calling handler.
+func (s *Handler) currentSession(w http.ResponseWriter, r *http.Request) (*model.Session, error, int) {
+	sessionUid := r.URL.Query().Get("session_uid")
+	if sessionUid == "" {
+		err, status := ErrMissingParam(w, "session_uid", s.rlog)
+		return nil, err, status
+	}
+	sess, err := s.sessionRepo.GetByUid(r.Context(), sessionUid)
+	if err == sql.ErrNoRows {
+		err, status := logResponse(w, "session not found",
+			s.rlog.WithField("session_uid", sessionUid), http.StatusNotFound)
+		return nil, err, status
+	}
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	state, round := sess.State, sess.CurrentRound
+	if session.Advance(sess, time.Now()) {
+		updated, err := s.sessionRepo.UpdateState(r.Context(), sess, state, round)
+		if err != nil {
+			return nil, err, http.StatusInternalServerError
+		}
+		if !updated {
+			// another request or the clock advanced the session meanwhile,
+			// its stored state is the current one
+			sess, err = s.sessionRepo.GetByUid(r.Context(), sessionUid)
+			if err != nil {
+				return nil, err, http.StatusInternalServerError
+			}
+		}
+	}
+	return sess, nil, http.StatusOK
+}
+
+// facilitatedSession works like currentSession, but only returns sessions
+// facilitated by the current user
+func (s *Handler) facilitatedSession(w http.ResponseWriter, r *http.Request) (*model.Session, error, int) {
+	sess, err, status := s.currentSession(w, r)
+	if sess == nil {
+		return nil, err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	if sess.FacilitatorID != user.ID {
+		err, status := logResponse(w, "user doesn't facilitate the session",
+			s.rlog.WithFields(logrus.Fields{
+				"session": sess.UniqueID,
+				"user":    user.Name,
+			}), http.StatusUnauthorized)
+		return nil, err, status
+	}
+	return sess, nil, http.StatusOK
+}
+
+// sessionAssignment returns the sheet the user works on in the current
+// round, or nil if the user has nothing to do
+func sessionAssignment(sess *model.Session, topic *model.Post, participants []*model.SessionParticipant,
+	contributions []*model.SessionContribution, user *model.User) *model.SessionAssignment {
+	seat := sessionSeat(participants, user)
+	if seat < 0 || sess.State != model.SessionRunning {
+		return nil
+	}
+	assignment := &model.SessionAssignment{
+		Round:    sess.CurrentRound,
+		Sheet:    session.Sheet(seat, sess.CurrentRound, len(participants)),
+		Deadline: sess.RoundDeadline,
+		Parents:  []string{},
+		Done:     []int{},
+	}
+	for idea := 1; idea <= sess.IdeasPerRound; idea++ {
+		parentUid := topic.UniqueID
+		if parent := sheetParent(contributions, sess.CurrentRound, assignment.Sheet, idea); parent != nil {
+			parentUid = parent.PostUid
+		}
+		assignment.Parents = append(assignment.Parents, parentUid)
+		if sessionContribution(contributions, sess.CurrentRound, assignment.Sheet, idea) != nil {
+			assignment.Done = append(assignment.Done, idea)
+		}
+	}
+	return assignment
+}
+
+// sessionSeat returns the seat of the user, or -1 if the user doesn't take part
+func sessionSeat(participants []*model.SessionParticipant, user *model.User) int {
+	for _, participant := range participants {
+		if participant.UserID == user.ID {
+			return participant.Seat
+		}
+	}
+	return -1
+}
+
+func sessionContribution(contributions []*model.SessionContribution, round, sheet, idea int) *model.SessionContribution {
+	for _, contribution := range contributions {
+		if contribution.Round == round && contribution.Sheet == sheet && contribution.Idea == idea {
+			return contribution
+		}
+	}
+	return nil
+}
+
+// sheetParent returns the latest contribution of an earlier round to the
+// idea on the sheet, new ideas build on it
+func sheetParent(contributions []*model.SessionContribution, round, sheet, idea int) *model.SessionContribution {
+	var parent *model.SessionContribution
+	for _, contribution := range contributions {
+		if contribution.Sheet != sheet || contribution.Idea != idea || contribution.Round >= round {
+			continue
+		}
+		if parent == nil || contribution.Round > parent.Round {
+			parent = contribution
+		}
+	}
+	return parent
+}
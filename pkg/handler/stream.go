@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.com/innoserver/pkg/live"
+	"gitlab.com/innoserver/pkg/model"
+)
+
+const (
+	streamKeepAlive = 15 * time.Second
+	// The server closes connections after a minute of writing, streams end
+	// before and are resumed by the clients with the Last-Event-ID
+	streamDuration = 50 * time.Second
+)
+
+// EventStream swagger:route GET /events live eventStream
+//
+// Streams the events of groups and posts as server-sent events, as an
+// alternative to the websocket of /live. Clients which can't set headers
+// may pass token and api_key in the query. After a reconnect the stream
+// continues after the Last-Event-ID, if events were missed in between a
+// reset event is sent and the client has to reload its state.
+//
+// responses:
+//     200: description: stream of events
+//     400: description: bad request
+//     401: description: user is not in the group
+//     503: description: live events are not available
+func (s *Handler) EventStream(w http.ResponseWriter, r *http.Request) (error, int) {
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if s.eventLog == nil {
+		return logResponse(w, "live events are not available", s.rlog.WithField("user", user.Name),
+			http.StatusServiceUnavailable)
+	}
+	subs := []live.Subscription{}
+	for _, groupUid := range r.URL.Query()["group_uid"] {
+		subs = append(subs, live.Subscription{GroupUid: groupUid})
+	}
+	for _, postUid := range r.URL.Query()["post_uid"] {
+		subs = append(subs, live.Subscription{PostUid: postUid})
+	}
+	if len(subs) == 0 {
+		return ErrMissingParam(w, "group_uid", s.rlog)
+	}
+	for _, sub := range subs {
+		if err := s.checkLiveSubscription(r.Context(), sub, user); err != nil {
+			return logResponse(w, err.Error(), s.rlog.WithField("user", user.Name), http.StatusUnauthorized)
+		}
+	}
+	cursor := s.eventLog.Last()
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = r.URL.Query().Get("last_event_id")
+	}
+	if resume != "" {
+		if cursor, err = strconv.ParseUint(resume, 10, 64); err != nil {
+			return logResponse(w, "invalid last event id", s.rlog.WithField("id", resume), http.StatusBadRequest)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// keeps proxies like nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	end := time.After(streamDuration)
+	for {
+		entries, last, complete, changed := s.eventLog.Since(cursor)
+		if !complete {
+			fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", last)
+			cursor = last
+			entries = nil
+		}
+		for _, entry := range entries {
+			cursor = entry.ID
+			if !matchesAny(subs, entry.Event) {
+				continue
+			}
+			data, err := json.Marshal(entry.Event)
+			if err != nil {
+				return err, http.StatusOK
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", entry.ID, entry.Event.Type, data)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			return nil, http.StatusOK
+		case <-end:
+			return nil, http.StatusOK
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-changed:
+		}
+	}
+}
+
+func matchesAny(subs []live.Subscription, e *model.Event) bool {
+	for _, sub := range subs {
+		if sub.Matches(e) {
+			return true
+		}
+	}
+	return false
+}
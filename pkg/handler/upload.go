@@ -1,14 +1,18 @@
 package handler
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"io"
 	"math/rand"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -18,50 +22,112 @@ import (
 	"gitlab.com/innoserver/pkg/model"
 )
 
-// Uploads a file through http MultipartForm
+// Uploads all files of a http MultipartForm stored under the same key
 //
-// @param Request: the current request
-// @param maxSize: the maximum file size
-// @param file:    the multiparts file key which is the name of the uploaded file
+// @param Request: the current request, its MultipartForm has to be parsed
+// @param file:    the multiparts file key which is the name of the uploaded files
 //
-// @param fType:   determine if filetype is wether image or video
-func (s *Handler) UploadFile(r *http.Request, maxSize int64, file string, fType int) (string, string, error) {
-	parseError := r.ParseMultipartForm(maxSize)
-	if parseError != nil {
-		return "", "", parseError
+// @param fType:   the filetype of files which are wether image nor video
+func (s *Handler) UploadFiles(r *http.Request, file string, fType int) ([]*model.PostMedia, error) {
+	media := []*model.PostMedia{}
+	if r.MultipartForm == nil {
+		return media, errors.New("multipart form isn't parsed")
 	}
-	upFile, handler, err := r.FormFile(file)
-	if err != nil {
-		return "", "", err
+	for i, header := range r.MultipartForm.File[file] {
+		contentType := header.Header.Get("Content-Type")
+		m := &model.PostMedia{
+			Position: i,
+			Type:     mediaTypeOfContentType(contentType, fType),
+			Size:     header.Size,
+		}
+		upFile, err := header.Open()
+		if err == nil {
+			m.Path, err = s.storeFile(r.Context(), upFile, extensionOfContentType(contentType), m.Type)
+			upFile.Close()
+		}
+		if err != nil {
+			s.releaseMedia(r.Context(), media)
+			return nil, err
+		}
+		media = append(media, m)
 	}
-	defer upFile.Close()
-	contentType := handler.Header.Get("Content-Type")
-	extension := "." + contentType[strings.LastIndex(contentType, "/")+1:]
+	return media, nil
+}
+
+// Writes the content of src into the output directory of the given filetype
+// and returns the name of the file. Files are stored content-addressed, so
+// identical content is stored only once and its reference count is increased.
+func (s *Handler) storeFile(ctx context.Context, src io.Reader, extension string, fType int) (string, error) {
 	outDir := initOutputDir(s, fType)
-	fileName := generateFileName()
+	tmpName := outDir + "." + generateFileName() + ".tmp"
+	f, err := os.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), src)
+	f.Close()
+	if err != nil {
+		os.Remove(tmpName)
+		return "", err
+	}
+	blob := &model.Blob{
+		Hash: hex.EncodeToString(hash.Sum(nil)),
+		Type: fType,
+		Size: size,
+	}
+	blob.Path = blob.Hash + extension
 
+	created, err := s.blobRepo.Acquire(ctx, blob)
+	if err != nil || !created {
+		os.Remove(tmpName)
+		if err == nil {
+			s.log.WithField("file", blob.Path).Infoln("reusing already stored file")
+		}
+		return blob.Path, err
+	}
 	s.log.WithFields(logrus.Fields{
-		"file": fileName + extension,
+		"file": blob.Path,
 		"path": outDir,
 	}).Infoln("writing uploaded file")
-
-	f, err := os.OpenFile(outDir+fileName+extension, os.O_WRONLY|os.O_CREATE, 0666)
-	if err != nil {
-		return "", "", err
+	if err := os.Rename(tmpName, outDir+blob.Path); err != nil {
+		os.Remove(tmpName)
+		s.releaseFile(ctx, fType, blob.Path)
+		return "", err
 	}
-	defer f.Close()
-	io.Copy(f, upFile)
-	if err != nil {
-		return "", "", err
+	return blob.Path, nil
+}
+
+// Decreases the reference count of a stored file and removes the file
+// as soon as no post references it anymore
+func (s *Handler) releaseFile(ctx context.Context, fType int, path string) error {
+	return s.blobRepo.Release(ctx, fType, path, func() error {
+		s.log.WithField("file", path).Infoln("removing unreferenced file")
+		if err := os.Remove(initOutputDir(s, fType) + path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+func extensionOfContentType(contentType string) string {
+	return "." + contentType[strings.LastIndex(contentType, "/")+1:]
+}
+
+func mediaTypeOfContentType(contentType string, fallback int) int {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return model.PostTypeImage
+	case strings.HasPrefix(contentType, "video/"):
+		return model.PostTypeVideo
+	case strings.HasPrefix(contentType, "audio/"):
+		return model.PostTypeAudio
 	}
-	return fileName + extension, fileName + "_thumbnail" + extension, nil
+	return fallback
 }
 
 func initOutputDir(s *Handler, fType int) string {
-	if fType == model.PostTypeImage {
-		return "." + s.config.ImagePath
-	}
-	return "." + s.config.VideoPath
+	return "." + mediaDirOfType(s, fType)
 }
 
 func generateFileName() string {
@@ -85,6 +151,17 @@ func initNewPostUpload(post *model.Post, s *Handler, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	post.Prompt = strings.ToUpper(r.FormValue("prompt"))
+	if position := r.FormValue("position"); position != "" {
+		if post.Position, err = strconv.Atoi(position); err != nil {
+			return err
+		}
+	}
+	if options := r.FormValue("options"); options != "" {
+		if err := json.Unmarshal([]byte(options), &post.Options); err != nil {
+			return err
+		}
+	}
 	gUid := r.URL.Query().Get("group_uid")
 	if gUid != "" {
 		if group, err := s.groupRepo.GetByUid(r.Context(), gUid); err == nil {
@@ -106,9 +183,73 @@ func initNewPostUpload(post *model.Post, s *Handler, r *http.Request) error {
 	return nil
 }
 
+func isValidPostType(fType int) bool {
+	return fType >= model.PostTypeImage && fType <= model.PostTypeSketch
+}
+
+// isFilePostType reports whether posts of the type are stored with files
+func isFilePostType(fType int) bool {
+	return fType == model.PostTypeImage || fType == model.PostTypeVideo ||
+		fType == model.PostTypeAudio
+}
+
+// initContentPost reads the content of posts which are stored without a file
+func initContentPost(post *model.Post, s *Handler, r *http.Request) error {
+	post.Content = r.FormValue("content")
+	post.Size = int64(len(post.Content))
+	switch post.Type {
+	case model.PostTypeText:
+		if post.Title == "" && post.Content == "" {
+			return errors.New("text post without title and content")
+		}
+		if s.config.MaxTextSize > 0 && post.Size > s.config.MaxTextSize {
+			return errors.New("text exceeds the maximum size")
+		}
+	case model.PostTypeSketch:
+		if s.config.MaxSketchSize > 0 && post.Size > s.config.MaxSketchSize {
+			return errors.New("sketch exceeds the maximum size")
+		}
+		return validateSketch(post.Content)
+	}
+	return nil
+}
+
+var strokeColor = regexp.MustCompile("^#([0-9a-fA-F]{6}|[0-9a-fA-F]{8})$")
+
+func validateSketch(content string) error {
+	sketch := &model.Sketch{}
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(sketch); err != nil {
+		return errors.New("sketch is no valid json: " + err.Error())
+	}
+	if sketch.Width <= 0 || sketch.Height <= 0 {
+		return errors.New("sketch canvas has no size")
+	}
+	if len(sketch.Strokes) == 0 {
+		return errors.New("sketch contains no strokes")
+	}
+	for _, stroke := range sketch.Strokes {
+		if stroke == nil || len(stroke.Points) == 0 || stroke.Width <= 0 {
+			return errors.New("sketch contains an empty stroke")
+		}
+		if !strokeColor.MatchString(stroke.Color) {
+			return errors.New("invalid stroke color " + stroke.Color)
+		}
+	}
+	return nil
+}
+
 func determineMaxPostSize(post *model.Post, s *Handler) int64 {
-	if post.Type == model.PostTypeImage {
+	return determineMaxFileSize(post.Type, s)
+}
+
+func determineMaxFileSize(fType int, s *Handler) int64 {
+	switch fType {
+	case model.PostTypeImage:
 		return s.config.MaxImageSize
+	case model.PostTypeAudio:
+		return s.config.MaxAudioSize
 	}
 	return s.config.MaxVideoSize
 }
@@ -64,7 +64,8 @@ func (s *Handler) Register(w http.ResponseWriter, r *http.Request) (error, int)
 
 // Register swagger:route GET /user/info user userInfo
 //
-// Fetch user Information with groups and posts
+// Fetch user Information with groups and posts, optionally together with
+// the collections of the user
 //
 // responses:
 //     200: UserWithPostsGroups
@@ -79,10 +80,28 @@ func (s *Handler) UserInfo(w http.ResponseWriter, r *http.Request) (error, int)
 		return err, http.StatusInternalServerError
 	}
 	posts, err := s.postRepo.SelectByUser(r.Context(), user)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	s.preparePosts(posts...)
+	usage, err := s.userUsage(r.Context(), user)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
 	resp := &model.UserWithPostsGroups{
 		User:   *user,
 		Groups: groups,
 		Posts:  posts,
+		Usage:  usage,
+	}
+	if r.URL.Query().Get("collections") == "true" {
+		resp.Collections, err = s.collectionRepo.SelectByUser(r.Context(), user)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+		if err := s.appendCollectionPosts(r.Context(), user, resp.Collections...); err != nil {
+			return err, http.StatusInternalServerError
+		}
 	}
 	resp.User.Password = ""
 	return WriteJsonResp(w, resp)
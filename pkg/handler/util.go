@@ -57,11 +57,9 @@ func generateUid(repo uniqueID, r *http.Request) (string, error) {
 		}
 		if !exists {
 			return uid.String(), nil
-			break
 		}
 		time.Sleep(100 * time.Millisecond)
 	}
-	return "", errors.New("unknown error while generating uid")
 }
 
 func SetJsonHeader(w http.ResponseWriter) {
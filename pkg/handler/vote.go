@@ -0,0 +1,427 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+const defaultVotesPerUser = 3
+
+// CreateVoting swagger:route POST /vote/create vote createVoting
+//
+// Starts a voting on the child posts of a post, or on the root posts of a
+// group. Votings on posts are created by the author of the post, votings on
+// groups by the group admin, who facilitate the voting.
+//
+// responses:
+//     200: uidResponse
+//     400: description: bad request
+//     401: description: user may not start a voting here
+//     500: description: internal server error
+func (s *Handler) CreateVoting(w http.ResponseWriter, r *http.Request) (error, int) {
+	details := &model.CreateVotingRequestBody{}
+	if err := json.NewDecoder(r.Body).Decode(&details.Info); err != nil {
+		return logResponse(w, "error encoding json",
+			s.rlog.WithFields(logrus.Fields{}).WithError(err), http.StatusBadRequest)
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	voting := &model.Voting{
+		FacilitatorID: user.ID,
+		Mode:          details.Info.Mode,
+		VotesPerUser:  details.Info.VotesPerUser,
+	}
+	switch {
+	case details.Info.PostUid != "":
+		post, err := s.postRepo.GetByUid(r.Context(), details.Info.PostUid)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+		if post.UserID != user.ID {
+			return logResponse(w, "only the author of a post may start a voting on it",
+				s.rlog.WithField("post_uid", post.UniqueID), http.StatusUnauthorized)
+		}
+		voting.PostID = sql.NullInt32{Int32: int32(post.ID), Valid: true}
+	case details.Info.GroupUid != "":
+		group, err := s.groupRepo.GetByUid(r.Context(), details.Info.GroupUid)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+		if group.AdminID != user.ID {
+			return logResponse(w, "only the group admin may start a voting on the group",
+				s.rlog.WithField("group_uid", group.UniqueID), http.StatusUnauthorized)
+		}
+		voting.GroupID = sql.NullInt32{Int32: int32(group.ID), Valid: true}
+	default:
+		return ErrMissingParam(w, "post_uid", s.rlog)
+	}
+	switch voting.Mode {
+	case model.VotingDots:
+		if voting.VotesPerUser <= 0 {
+			voting.VotesPerUser = s.config.VotesPerUser
+		}
+		if voting.VotesPerUser <= 0 {
+			voting.VotesPerUser = defaultVotesPerUser
+		}
+	case model.VotingRating:
+		voting.VotesPerUser = 0
+	default:
+		return logResponse(w, "unknown voting mode",
+			s.rlog.WithField("mode", voting.Mode), http.StatusBadRequest)
+	}
+	voting.UniqueID, err = generateUid(s.votingRepo, r)
+	if err != nil || voting.UniqueID == "" {
+		return err, http.StatusInternalServerError
+	}
+	if err := s.votingRepo.Persist(r.Context(), voting); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	s.log.WithFields(logrus.Fields{
+		"voting": voting.UniqueID, "mode": voting.Mode, "user": user.Name,
+	}).Infoln("voting created")
+	return WriteJsonResp(w, &model.UidResponse{UniqueID: voting.UniqueID})
+}
+
+// CastVote swagger:route GET /vote/cast vote castVote
+//
+// Gives a post a number of dots or a rating. A later vote on the same post
+// replaces the earlier one.
+//
+// responses:
+//     200: VotingResults
+//     400: description: bad request
+//     401: description: user is not in the group of the voting
+//     404: description: voting not found
+//     409: description: voting closed or no dots left
+//     500: description: internal server error
+func (s *Handler) CastVote(w http.ResponseWriter, r *http.Request) (error, int) {
+	voting, post, user, err, status := s.votingCandidate(w, r)
+	if voting == nil {
+		return err, status
+	}
+	value, err := strconv.Atoi(r.URL.Query().Get("value"))
+	if err != nil {
+		return ErrMissingParam(w, "value", s.rlog)
+	}
+	if value < 1 || (voting.Mode == model.VotingRating && value > model.MaxRating) {
+		return logResponse(w, "invalid vote value",
+			s.rlog.WithField("value", value), http.StatusBadRequest)
+	}
+	// only dots are limited, ratings can be given to every post
+	budget := 0
+	if voting.Mode == model.VotingDots {
+		budget = voting.VotesPerUser
+	}
+	used, err := s.votingRepo.CastVote(r.Context(), &model.Vote{
+		VotingID: voting.ID,
+		UserID:   user.ID,
+		PostID:   post.ID,
+		Value:    value,
+	}, budget)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if budget > 0 && used > budget {
+		return logResponse(w, "not enough dots left",
+			s.rlog.WithFields(logrus.Fields{
+				"voting": voting.UniqueID,
+				"used":   used,
+			}), http.StatusConflict)
+	}
+	// the values stay hidden until the voting is closed
+	e := s.postEvent(r.Context(), model.EventVoteCast, user, post)
+	e.Data = voting.UniqueID
+	s.publish(e)
+	return s.writeVotingResults(w, r, voting, user)
+}
+
+// RetractVote swagger:route GET /vote/retract vote retractVote
+//
+// Removes the vote of the current user from a post
+//
+// responses:
+//     200: VotingResults
+//     400: description: bad request
+//     404: description: voting not found
+//     409: description: voting closed
+//     500: description: internal server error
+func (s *Handler) RetractVote(w http.ResponseWriter, r *http.Request) (error, int) {
+	voting, post, user, err, status := s.votingCandidate(w, r)
+	if voting == nil {
+		return err, status
+	}
+	err = s.votingRepo.RetractVote(r.Context(), &model.Vote{
+		VotingID: voting.ID,
+		UserID:   user.ID,
+		PostID:   post.ID,
+	})
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return s.writeVotingResults(w, r, voting, user)
+}
+
+// CloseVoting swagger:route GET /vote/close vote closeVoting
+//
+// Ends a voting and publishes its results, only the facilitator may close it
+//
+// responses:
+//     200: VotingResults
+//     401: description: user is not the facilitator
+//     404: description: voting not found
+//     500: description: internal server error
+func (s *Handler) CloseVoting(w http.ResponseWriter, r *http.Request) (error, int) {
+	voting, err, status := s.currentVoting(w, r)
+	if voting == nil {
+		return err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if voting.FacilitatorID != user.ID {
+		return logResponse(w, "user doesn't facilitate the voting",
+			s.rlog.WithFields(logrus.Fields{
+				"voting": voting.UniqueID,
+				"user":   user.Name,
+			}), http.StatusUnauthorized)
+	}
+	voting.State = model.VotingClosed
+	if err := s.votingRepo.UpdateState(r.Context(), voting); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err := s.publishVotingClosed(r.Context(), user, voting); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return s.writeVotingResults(w, r, voting, user)
+}
+
+// VotingResults swagger:route GET /vote/results vote votingResults
+//
+// Returns the votes of the current user and the dots left. The ranking of
+// the posts is included once the voting was closed.
+//
+// responses:
+//     200: VotingResults
+//     400: description: bad request
+//     401: description: user is not in the group of the voting
+//     404: description: voting not found
+//     500: description: internal server error
+func (s *Handler) VotingResults(w http.ResponseWriter, r *http.Request) (error, int) {
+	voting, err, status := s.currentVoting(w, r)
+	if voting == nil {
+		return err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	groupID, err := s.votingGroup(r.Context(), voting)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err, status := s.checkGroupAccess(w, r, groupID, user); err != nil || status != http.StatusOK {
+		return err, status
+	}
+	return s.writeVotingResults(w, r, voting, user)
+}
+
+// publishVotingClosed notifies the voters that the results are available
+func (s *Handler) publishVotingClosed(ctx context.Context, user *model.User, voting *model.Voting) error {
+	groupID, err := s.votingGroup(ctx, voting)
+	if err != nil {
+		return err
+	}
+	e := s.groupEvent(ctx, model.EventVotingClosed, user, groupID)
+	if voting.PostID.Valid {
+		post, err := s.postRepo.GetByID(ctx, int(voting.PostID.Int32))
+		if err != nil {
+			return err
+		}
+		e.PostUid = post.UniqueID
+	}
+	e.Data = voting.UniqueID
+	e.Recipients, err = s.votingRepo.SelectVoters(ctx, voting)
+	if err != nil {
+		return err
+	}
+	s.publish(e)
+	return nil
+}
+
+func (s *Handler) writeVotingResults(w http.ResponseWriter, r *http.Request, voting *model.Voting,
+	user *model.User) (error, int) {
+	votes, err := s.votingRepo.SelectVotesOfUser(r.Context(), voting, user)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	results := &model.VotingResults{Voting: voting, Votes: votes, Ranking: []*model.VoteResult{}}
+	if voting.Mode == model.VotingDots {
+		results.Remaining = voting.VotesPerUser
+		for _, vote := range votes {
+			results.Remaining -= vote.Value
+		}
+	}
+	if voting.State == model.VotingClosed {
+		if results.Ranking, err = s.votingRanking(r.Context(), voting); err != nil {
+			return err, http.StatusInternalServerError
+		}
+	}
+	return WriteJsonResp(w, results)
+}
+
+// votingRanking sorts all candidates of the voting by their score, posts
+// with equal scores share their rank
+func (s *Handler) votingRanking(ctx context.Context, voting *model.Voting) ([]*model.VoteResult, error) {
+	candidates, err := s.votingCandidates(ctx, voting)
+	if err != nil {
+		return nil, err
+	}
+	tallies, err := s.votingRepo.SelectTallies(ctx, voting)
+	if err != nil {
+		return nil, err
+	}
+	byPost := map[int]*model.VoteTally{}
+	for _, tally := range tallies {
+		byPost[tally.PostID] = tally
+	}
+	s.preparePosts(candidates...)
+	ranking := []*model.VoteResult{}
+	for _, post := range candidates {
+		result := &model.VoteResult{Post: post}
+		if tally, ok := byPost[post.ID]; ok {
+			result.Voters = tally.Voters
+			result.Score = float64(tally.Total)
+			if voting.Mode == model.VotingRating {
+				result.Score /= float64(tally.Voters)
+			}
+		}
+		ranking = append(ranking, result)
+	}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		if ranking[i].Score != ranking[j].Score {
+			return ranking[i].Score > ranking[j].Score
+		}
+		return ranking[i].Voters > ranking[j].Voters
+	})
+	for i, result := range ranking {
+		result.Rank = i + 1
+		if i > 0 && result.Score == ranking[i-1].Score && result.Voters == ranking[i-1].Voters {
+			result.Rank = ranking[i-1].Rank
+		}
+	}
+	return ranking, nil
+}
+
+// votingCandidates returns the posts which can be voted on
+func (s *Handler) votingCandidates(ctx context.Context, voting *model.Voting) ([]*model.Post, error) {
+	if voting.PostID.Valid {
+		parent, err := s.postRepo.GetByID(ctx, int(voting.PostID.Int32))
+		if err != nil {
+			return nil, err
+		}
+		return s.postRepo.SelectByParent(ctx, parent)
+	}
+	group, err := s.groupRepo.GetByID(ctx, int(voting.GroupID.Int32))
+	if err != nil {
+		return nil, err
+	}
+	posts, err := s.postRepo.SelectByGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	roots := []*model.Post{}
+	for _, post := range posts {
+		if !post.ParentID.Valid {
+			roots = append(roots, post)
+		}
+	}
+	return roots, nil
+}
+
+// votingGroup returns the group whose members may take part in the voting
+func (s *Handler) votingGroup(ctx context.Context, voting *model.Voting) (sql.NullInt32, error) {
+	if !voting.PostID.Valid {
+		return voting.GroupID, nil
+	}
+	parent, err := s.postRepo.GetByID(ctx, int(voting.PostID.Int32))
+	if err != nil {
+		return sql.NullInt32{}, err
+	}
+	return parent.GroupID, nil
+}
+
+// currentVoting fetches the voting of the request. If the returned voting
+// is nil, the error and status have to be returned by the calling handler.
+func (s *Handler) currentVoting(w http.ResponseWriter, r *http.Request) (*model.Voting, error, int) {
+	votingUid := r.URL.Query().Get("voting_uid")
+	if votingUid == "" {
+		err, status := ErrMissingParam(w, "voting_uid", s.rlog)
+		return nil, err, status
+	}
+	voting, err := s.votingRepo.GetByUid(r.Context(), votingUid)
+	if err == sql.ErrNoRows {
+		err, status := logResponse(w, "voting not found",
+			s.rlog.WithField("voting_uid", votingUid), http.StatusNotFound)
+		return nil, err, status
+	}
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	return voting, nil, http.StatusOK
+}
+
+// votingCandidate fetches the open voting and the post of the request and
+// ensures the user may vote on the post. If the returned voting is nil, the
+// error and status have to be returned by the calling handler.
+func (s *Handler) votingCandidate(w http.ResponseWriter, r *http.Request) (*model.Voting, *model.Post,
+	*model.User, error, int) {
+	voting, err, status := s.currentVoting(w, r)
+	if voting == nil {
+		return nil, nil, nil, err, status
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return nil, nil, nil, err, http.StatusInternalServerError
+	}
+	if voting.State != model.VotingOpen {
+		err, status := logResponse(w, "voting is closed",
+			s.rlog.WithField("voting", voting.UniqueID), http.StatusConflict)
+		return nil, nil, nil, err, status
+	}
+	postUid := r.URL.Query().Get("post_uid")
+	if postUid == "" {
+		err, status := ErrMissingParam(w, "post_uid", s.rlog)
+		return nil, nil, nil, err, status
+	}
+	post, err := s.postRepo.GetByUid(r.Context(), postUid)
+	if err != nil {
+		return nil, nil, nil, err, http.StatusInternalServerError
+	}
+	candidate := voting.PostID.Valid && post.ParentID == voting.PostID
+	if !voting.PostID.Valid {
+		candidate = !post.ParentID.Valid && post.GroupID == voting.GroupID
+	}
+	if !candidate {
+		err, status := logResponse(w, "post is not part of the voting",
+			s.rlog.WithFields(logrus.Fields{
+				"voting":   voting.UniqueID,
+				"post_uid": post.UniqueID,
+			}), http.StatusBadRequest)
+		return nil, nil, nil, err, status
+	}
+	if err, status := s.checkGroupAccess(w, r, post.GroupID, user); err != nil || status != http.StatusOK {
+		return nil, nil, nil, err, status
+	}
+	return voting, post, user, nil, http.StatusOK
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+	"gitlab.com/innoserver/pkg/webhook"
+)
+
+// The number of deliveries returned by the delivery log
+const webhookDeliveryLimit = 50
+
+// CreateWebhook swagger:route POST /group/webhooks/create group createWebhook
+//
+// Registers a URL receiving the selected types of events of the group. The
+// secret the payloads are signed with is only returned once. URLs of the
+// local network are refused unless their host is allowed by the config.
+//
+// responses:
+//     200: Webhook
+//     400: description: bad request
+//     401: description: user is not the admin of the group
+//     500: description: internal server error
+//     503: description: webhooks are not available
+func (s *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) (error, int) {
+	details := &model.CreateWebhookRequestBody{}
+	if err := json.NewDecoder(r.Body).Decode(&details.Info); err != nil {
+		return logResponse(w, "error encoding json",
+			s.rlog.WithFields(logrus.Fields{}).WithError(err), http.StatusBadRequest)
+	}
+	if details.Info.Url == "" {
+		return ErrMissingParam(w, "url", s.rlog)
+	}
+	if s.webhooks == nil {
+		return logResponse(w, "webhooks are not available",
+			s.rlog.WithField("url", details.Info.Url), http.StatusServiceUnavailable)
+	}
+	if err := s.webhooks.CheckURL(r.Context(), details.Info.Url); err != nil {
+		return logResponse(w, "invalid webhook url",
+			s.rlog.WithField("url", details.Info.Url).WithError(err), http.StatusBadRequest)
+	}
+	if len(details.Info.Events) == 0 {
+		return ErrMissingParam(w, "events", s.rlog)
+	}
+	for _, kind := range details.Info.Events {
+		if !webhook.Supported(kind) {
+			return logResponse(w, "unsupported event type",
+				s.rlog.WithField("type", kind), http.StatusBadRequest)
+		}
+	}
+	groupUid := r.URL.Query().Get("group_uid")
+	group, err := s.groupRepo.GetByUid(r.Context(), groupUid)
+	if err != nil {
+		return logResponse(w, "error fetching group from db",
+			s.rlog.WithField("group_uid", groupUid).WithError(err),
+			http.StatusInternalServerError)
+	}
+	hook := &model.Webhook{
+		GroupID: group.ID,
+		Url:     details.Info.Url,
+		Events:  details.Info.Events,
+	}
+	hook.UniqueID, err = generateUid(s.webhookRepo, r)
+	if err != nil || hook.UniqueID == "" {
+		return err, http.StatusInternalServerError
+	}
+	hook.Secret, err = webhook.NewSecret()
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err := s.webhookRepo.Persist(r.Context(), hook); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	created, err := s.webhookRepo.GetByUid(r.Context(), hook.UniqueID)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return WriteJsonResp(w, created)
+}
+
+// ListWebhooks swagger:route GET /group/webhooks/list group listWebhooks
+//
+// Returns the webhooks of the group without their secrets
+//
+// responses:
+//     200: []Webhook
+//     401: description: user is not the admin of the group
+//     500: description: internal server error
+func (s *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) (error, int) {
+	groupUid := r.URL.Query().Get("group_uid")
+	group, err := s.groupRepo.GetByUid(r.Context(), groupUid)
+	if err != nil {
+		return logResponse(w, "error fetching group from db",
+			s.rlog.WithField("group_uid", groupUid).WithError(err),
+			http.StatusInternalServerError)
+	}
+	hooks, err := s.webhookRepo.SelectByGroup(r.Context(), group.ID)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+	return WriteJsonResp(w, hooks)
+}
+
+// RemoveWebhook swagger:route GET /group/webhooks/remove group removeWebhook
+//
+// Removes a webhook of the group together with its delivery log
+//
+// responses:
+//     200: description: successfully removed webhook
+//     400: description: bad request
+//     401: description: user is not the admin of the group
+//     404: description: webhook not found
+//     500: description: internal server error
+func (s *Handler) RemoveWebhook(w http.ResponseWriter, r *http.Request) (error, int) {
+	hook, err, status := s.currentWebhook(w, r)
+	if hook == nil {
+		return err, status
+	}
+	if err := s.webhookRepo.Remove(r.Context(), hook); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// TestWebhook swagger:route GET /group/webhooks/test group testWebhook
+//
+// Sends a ping event to the webhook right away and returns the outcome of
+// the delivery. Failed test deliveries are retried like any other delivery.
+//
+// responses:
+//     200: WebhookDelivery
+//     400: description: bad request
+//     401: description: user is not the admin of the group
+//     404: description: webhook not found
+//     500: description: internal server error
+//     503: description: webhooks are not available
+func (s *Handler) TestWebhook(w http.ResponseWriter, r *http.Request) (error, int) {
+	hook, err, status := s.currentWebhook(w, r)
+	if hook == nil {
+		return err, status
+	}
+	if s.webhooks == nil {
+		return logResponse(w, "webhooks are not available",
+			s.rlog.WithField("webhook_uid", hook.UniqueID), http.StatusServiceUnavailable)
+	}
+	user, err := GetCurrentUser(r)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	payload, err := json.Marshal(&model.Event{
+		Type:      model.EventPing,
+		ActorID:   user.ID,
+		Actor:     user.Name,
+		GroupUid:  r.URL.Query().Get("group_uid"),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	delivery, err := webhook.NewDelivery(hook, model.EventPing, payload)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err := s.webhookRepo.AddDelivery(r.Context(), delivery); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err := s.webhooks.Attempt(r.Context(), delivery); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	s.log.WithFields(logrus.Fields{
+		"webhook": hook.UniqueID,
+		"status":  delivery.LastStatus,
+		"error":   delivery.LastError,
+	}).Infoln("webhook test delivery")
+	return WriteJsonResp(w, delivery)
+}
+
+// WebhookDeliveries swagger:route GET /group/webhooks/deliveries group webhookDeliveries
+//
+// Returns the latest deliveries of the webhook, pending ones included
+//
+// responses:
+//     200: []WebhookDelivery
+//     400: description: bad request
+//     401: description: user is not the admin of the group
+//     404: description: webhook not found
+//     500: description: internal server error
+func (s *Handler) WebhookDeliveries(w http.ResponseWriter, r *http.Request) (error, int) {
+	hook, err, status := s.currentWebhook(w, r)
+	if hook == nil {
+		return err, status
+	}
+	deliveries, err := s.webhookRepo.SelectDeliveries(r.Context(), hook, webhookDeliveryLimit)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return WriteJsonResp(w, deliveries)
+}
+
+// currentWebhook fetches the webhook of the request, which has to belong to
+// the group of the request. If the returned webhook is nil, the error and
+// status have to be returned by the calling handler.
+func (s *Handler) currentWebhook(w http.ResponseWriter, r *http.Request) (*model.Webhook, error, int) {
+	webhookUid := r.URL.Query().Get("webhook_uid")
+	if webhookUid == "" {
+		err, status := ErrMissingParam(w, "webhook_uid", s.rlog)
+		return nil, err, status
+	}
+	groupUid := r.URL.Query().Get("group_uid")
+	group, err := s.groupRepo.GetByUid(r.Context(), groupUid)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+	hook, err := s.webhookRepo.GetByUid(r.Context(), webhookUid)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err, http.StatusInternalServerError
+	}
+	// webhooks of other groups are reported as missing to not reveal them
+	if err == sql.ErrNoRows || hook.GroupID != group.ID {
+		err, status := logResponse(w, "webhook not found",
+			s.rlog.WithField("webhook_uid", webhookUid), http.StatusNotFound)
+		return nil, err, status
+	}
+	return hook, nil, http.StatusOK
+}
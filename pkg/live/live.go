@@ -0,0 +1,148 @@
+// Package live fans the events of groups and posts out to connected
+// clients, so they don't have to poll for new content during a workshop.
+package live
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// The events passed to the clients, personal events like mentions are
+// delivered as notifications instead
+var liveEvents = map[string]bool{
+	model.EventPostCreated:    true,
+	model.EventPostRemoved:    true,
+	model.EventOptionsChanged: true,
+	model.EventVoteCast:       true,
+	model.EventVotingClosed:   true,
+}
+
+// The number of events buffered per client, clients falling further behind
+// are disconnected
+const clientBuffer = 64
+
+// A subscription to the events of a group or of a post and its children
+type Subscription struct {
+	GroupUid string `json:"group_uid,omitempty"`
+	PostUid  string `json:"post_uid,omitempty"`
+}
+
+// Matches reports whether the event belongs to the subscription
+func (sub Subscription) Matches(e *model.Event) bool {
+	if sub.GroupUid != "" && sub.GroupUid == e.GroupUid {
+		return true
+	}
+	return sub.PostUid != "" && (sub.PostUid == e.PostUid || sub.PostUid == e.ParentUid)
+}
+
+// Client receives the events matching its subscriptions
+type Client struct {
+	events        chan *model.Event
+	lock          sync.RWMutex
+	subscriptions map[Subscription]bool
+}
+
+// Events returns the events of the client, the channel is closed when the
+// client is unregistered
+func (c *Client) Events() <-chan *model.Event {
+	return c.events
+}
+
+func (c *Client) Subscribe(sub Subscription) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.subscriptions[sub] = true
+}
+
+func (c *Client) Unsubscribe(sub Subscription) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.subscriptions, sub)
+}
+
+// Subscriptions returns the current subscriptions of the client
+func (c *Client) Subscriptions() []Subscription {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	subs := make([]Subscription, 0, len(c.subscriptions))
+	for sub := range c.subscriptions {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+func (c *Client) matches(e *model.Event) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for sub := range c.subscriptions {
+		if sub.Matches(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hub keeps the connected clients and passes them the events they subscribed to
+type Hub struct {
+	lock    sync.RWMutex
+	clients map[*Client]bool
+	log     *logrus.Logger
+}
+
+func NewHub(log *logrus.Logger) *Hub {
+	return &Hub{
+		clients: map[*Client]bool{},
+		log:     log,
+	}
+}
+
+// Register adds a client without any subscriptions
+func (h *Hub) Register() *Client {
+	c := &Client{
+		events:        make(chan *model.Event, clientBuffer),
+		subscriptions: map[Subscription]bool{},
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.clients[c] = true
+	return c
+}
+
+// Unregister removes the client and closes its events, unregistering a
+// client twice has no effect
+func (h *Hub) Unregister(c *Client) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.unregister(c)
+}
+
+func (h *Hub) unregister(c *Client) {
+	if h.clients[c] {
+		delete(h.clients, c)
+		close(c.events)
+	}
+}
+
+// Publish passes the event to all clients subscribed to it, it is meant to
+// be subscribed to the event bus. Clients which don't keep up are dropped.
+func (h *Hub) Publish(e *model.Event) {
+	if !liveEvents[e.Type] {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for c := range h.clients {
+		if !c.matches(e) {
+			continue
+		}
+		select {
+		case c.events <- e:
+		default:
+			h.log.WithField("type", e.Type).Warnln("dropping live client which doesn't keep up")
+			h.unregister(c)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package live
+
+import (
+	"sync"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// An event of the log with its sequence number
+type Entry struct {
+	ID    uint64
+	Event *model.Event
+}
+
+// Log keeps the latest events, so clients of event streams can resume after
+// a reconnect. The numbering starts anew when the server restarts.
+type Log struct {
+	lock    sync.Mutex
+	entries []*Entry
+	start   int
+	next    uint64
+	changed chan struct{}
+}
+
+// NewLog creates a log holding up to size events
+func NewLog(size int) *Log {
+	if size < 1 {
+		size = 1
+	}
+	return &Log{
+		entries: make([]*Entry, 0, size),
+		next:    1,
+		changed: make(chan struct{}),
+	}
+}
+
+// Append adds the event to the log, dropping the oldest event if the log is
+// full. It is meant to be subscribed to the event bus.
+func (l *Log) Append(e *model.Event) {
+	if !liveEvents[e.Type] {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	entry := &Entry{ID: l.next, Event: e}
+	l.next++
+	if len(l.entries) < cap(l.entries) {
+		l.entries = append(l.entries, entry)
+	} else {
+		l.entries[l.start] = entry
+		l.start = (l.start + 1) % len(l.entries)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Last returns the number of the latest event
+func (l *Log) Last() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.next - 1
+}
+
+// Since returns the events following the event with number id, the number
+// of the latest event and a channel closed on the next append. complete is
+// false if events after id were already dropped or id is unknown, clients
+// have to reload their state then and continue after last.
+func (l *Log) Since(id uint64) (entries []*Entry, last uint64, complete bool, changed <-chan struct{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	last = l.next - 1
+	if id >= l.next {
+		return nil, last, false, l.changed
+	}
+	complete = true
+	if len(l.entries) > 0 && l.entries[l.start].ID > id+1 {
+		complete = false
+	}
+	for i := range l.entries {
+		entry := l.entries[(l.start+i)%len(l.entries)]
+		if entry.ID > id {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, last, complete, l.changed
+}
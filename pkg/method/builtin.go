@@ -0,0 +1,68 @@
+package method
+
+import "gitlab.com/innoserver/pkg/model"
+
+// The ids of the built in methods, they are stored with every post and
+// must never change
+const (
+	OneZeroOne = iota
+	LotusBlossom
+	Scamper
+	SixThreeFive
+)
+
+const (
+	// LotusPetals is the number of petals around every theme of a lotus blossom
+	LotusPetals = 8
+	// LotusDepth is the depth of the outer petals below the center
+	LotusDepth = 2
+)
+
+func init() {
+	Register(&Method{
+		ID:            OneZeroOne,
+		Name:          "101 Method",
+		Description:   "Collect as many ideas on the topic as possible, one idea per post",
+		Rounds:        true,
+		IdeasPerRound: 1,
+		Options: []*model.OptionSchema{
+			{Key: "goal", Type: model.OptionTypeInt, Default: "101",
+				Description: "The number of ideas the group aims for"},
+		},
+	})
+	Register(&Method{
+		ID:   LotusBlossom,
+		Name: "Lotus Blossom",
+		Description: "The central theme is surrounded by eight petals, " +
+			"each petal is the theme of eight further petals",
+		MaxDepth:  LotusDepth,
+		Positions: LotusPetals,
+		Options: []*model.OptionSchema{
+			{Key: "color", Type: model.OptionTypeColor, Default: "#f4a7b9",
+				Description: "The color the petals are drawn with"},
+		},
+	})
+	Register(&Method{
+		ID:          Scamper,
+		Name:        "Scamper",
+		Description: "Answer the seven scamper prompts on the topic",
+		Prompts:     model.ScamperPrompts,
+		Options: []*model.OptionSchema{
+			{Key: "show_questions", Type: model.OptionTypeBool, Default: "true",
+				Description: "Show the question of each prompt next to its letter"},
+		},
+	})
+	Register(&Method{
+		ID:   SixThreeFive,
+		Name: "6-3-5 Method",
+		Description: "Six participants write down three ideas in five minutes " +
+			"and pass them on to build on the ideas of the others",
+		Rounds:        true,
+		IdeasPerRound: 3,
+		Options: []*model.OptionSchema{
+			{Key: "visibility", Type: model.OptionTypeEnum, Default: "open",
+				Values:      []string{"open", "anonymous"},
+				Description: "Whether the authors of the ideas are shown"},
+		},
+	})
+}
@@ -0,0 +1,170 @@
+// Package method contains the registry of the creativity methods. Every
+// method declares the structure its posts have to follow, the handlers
+// validate new posts against the method of their root post.
+package method
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// Posts gives validators access to already existing posts
+type Posts interface {
+	SelectByParent(ctx context.Context, parent *model.Post) ([]*model.Post, error)
+}
+
+// Validator checks a new post below the root of the method. The ancestors
+// start with the parent of the post and end with the root post.
+type Validator func(ctx context.Context, posts Posts, post *model.Post, ancestors []*model.Post) error
+
+// A creativity method
+//
+// swagger:model
+type Method struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// The maximum depth of posts below the root post, 0 means unlimited
+	MaxDepth int `json:"max_depth"`
+	// The number of cells below each post, posts have to take a free
+	// position from 1 to positions. 0 means posts have no position.
+	Positions int `json:"positions"`
+	// The prompts answers to the root post have to pick, deeper posts
+	// inherit the prompt of the answer
+	Prompts []*model.ScamperPrompt `json:"prompts"`
+	// The options posts of the method may carry
+	Options []*model.OptionSchema `json:"options"`
+	// Round based methods are held in timed sessions
+	Rounds bool `json:"rounds"`
+	// The default number of ideas per participant and round
+	IdeasPerRound int `json:"ideas_per_round,omitempty"`
+
+	Validators []Validator `json:"-"`
+}
+
+// Error is returned by validations, the status is used for the response
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Invalid returns a validation error for malformed posts
+func Invalid(format string, a ...interface{}) error {
+	return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf(format, a...)}
+}
+
+// Conflict returns a validation error for posts colliding with existing ones
+func Conflict(format string, a ...interface{}) error {
+	return &Error{Status: http.StatusConflict, Message: fmt.Sprintf(format, a...)}
+}
+
+var registry = map[int]*Method{}
+
+// Register adds a method to the registry, ids must be unique
+func Register(method *Method) {
+	if _, ok := registry[method.ID]; ok {
+		panic(fmt.Sprintf("method %d registered twice", method.ID))
+	}
+	if method.Prompts == nil {
+		method.Prompts = []*model.ScamperPrompt{}
+	}
+	if method.Options == nil {
+		method.Options = []*model.OptionSchema{}
+	}
+	registry[method.ID] = method
+}
+
+// Get returns the registered method with the id
+func Get(id int) (*Method, bool) {
+	method, ok := registry[id]
+	return method, ok
+}
+
+// All returns every registered method sorted by id
+func All() []*Method {
+	methods := make([]*Method, 0, len(registry))
+	for _, method := range registry {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].ID < methods[j].ID
+	})
+	return methods
+}
+
+// CheckPost verifies that a new post fits into the structure of the method.
+// The ancestors start with the parent of the post and end with the root post.
+func (m *Method) CheckPost(ctx context.Context, posts Posts, post *model.Post, ancestors []*model.Post) error {
+	if m.MaxDepth > 0 && len(ancestors) > m.MaxDepth {
+		return Invalid("posts of the method %s can't be nested any deeper", m.Name)
+	}
+	if m.Positions > 0 {
+		if err := m.checkPosition(ctx, posts, post, ancestors[0]); err != nil {
+			return err
+		}
+	}
+	if len(m.Prompts) > 0 {
+		if err := m.checkPrompt(post, ancestors); err != nil {
+			return err
+		}
+	} else if post.Prompt != "" {
+		return Invalid("posts of the method %s take no prompt", m.Name)
+	}
+	for _, validate := range m.Validators {
+		if err := validate(ctx, posts, post, ancestors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Method) checkPosition(ctx context.Context, posts Posts, post *model.Post, parent *model.Post) error {
+	if post.Position < 1 || post.Position > m.Positions {
+		return Invalid("posts of the method %s need a position from 1 to %d", m.Name, m.Positions)
+	}
+	siblings, err := posts.SelectByParent(ctx, parent)
+	if err != nil {
+		return err
+	}
+	for _, sibling := range siblings {
+		if sibling.Position == post.Position {
+			return Conflict("position %d below %s is already taken", post.Position, parent.UniqueID)
+		}
+	}
+	return nil
+}
+
+// checkPrompt verifies that answers to the root post pick one of the
+// prompts, deeper posts inherit the prompt of the answer they belong to
+func (m *Method) checkPrompt(post *model.Post, ancestors []*model.Post) error {
+	if len(ancestors) == 1 {
+		if m.Prompt(post.Prompt) == nil {
+			return Invalid("answers to posts of the method %s need one of its prompts", m.Name)
+		}
+		return nil
+	}
+	prompt := ancestors[len(ancestors)-2].Prompt
+	if post.Prompt != "" && post.Prompt != prompt {
+		return Invalid("post doesn't answer the prompt %s of its parent", prompt)
+	}
+	post.Prompt = prompt
+	return nil
+}
+
+// Prompt returns the prompt with the letter, or nil if the method has no such prompt
+func (m *Method) Prompt(letter string) *model.ScamperPrompt {
+	for _, prompt := range m.Prompts {
+		if prompt.Letter == letter {
+			return prompt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package method
+
+import (
+	"regexp"
+	"strconv"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+var colorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// maxOptionLength is the size of the value column
+const maxOptionLength = 255
+
+// Schema returns the declaration of the option key, or nil if the method
+// doesn't declare it
+func (m *Method) Schema(key string) *model.OptionSchema {
+	for _, schema := range m.Options {
+		if schema.Key == key {
+			return schema
+		}
+	}
+	return nil
+}
+
+// CheckOptions validates the complete option set of a post against the
+// schema and returns the normalised options. Missing options are fine as
+// long as they have a default. Methods without schema accept any option.
+func (m *Method) CheckOptions(options []*model.Option) ([]*model.Option, error) {
+	if len(m.Options) == 0 {
+		return options, nil
+	}
+	checked := []*model.Option{}
+	seen := map[string]bool{}
+	for _, option := range options {
+		schema := m.Schema(option.Key)
+		if schema == nil {
+			return nil, Invalid("the method %s has no option %s", m.Name, option.Key)
+		}
+		if seen[option.Key] {
+			return nil, Invalid("option %s is set twice", option.Key)
+		}
+		seen[option.Key] = true
+		value, err := checkValue(schema, option.Value)
+		if err != nil {
+			return nil, err
+		}
+		checked = append(checked, &model.Option{Key: option.Key, Value: value, Type: schema.Type})
+	}
+	for _, schema := range m.Options {
+		if seen[schema.Key] {
+			continue
+		}
+		if schema.Required && schema.Default == "" {
+			return nil, Invalid("posts of the method %s need the option %s", m.Name, schema.Key)
+		}
+	}
+	return checked, nil
+}
+
+// Describe sets the types of the options, so they are returned as typed
+// json values, and adds the defaults of all missing options
+func (m *Method) Describe(options []*model.Option) []*model.Option {
+	seen := map[string]bool{}
+	for _, option := range options {
+		if schema := m.Schema(option.Key); schema != nil {
+			option.Type = schema.Type
+		}
+		seen[option.Key] = true
+	}
+	for _, schema := range m.Options {
+		if !seen[schema.Key] && schema.Default != "" {
+			options = append(options, &model.Option{Key: schema.Key, Value: schema.Default, Type: schema.Type})
+		}
+	}
+	return options
+}
+
+// checkValue verifies a value against the schema and returns it in its
+// canonical form
+func checkValue(schema *model.OptionSchema, value string) (string, error) {
+	switch schema.Type {
+	case model.OptionTypeInt:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", Invalid("option %s needs an integer", schema.Key)
+		}
+		return strconv.FormatInt(i, 10), nil
+	case model.OptionTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", Invalid("option %s needs a boolean", schema.Key)
+		}
+		return strconv.FormatBool(b), nil
+	case model.OptionTypeEnum:
+		for _, allowed := range schema.Values {
+			if value == allowed {
+				return value, nil
+			}
+		}
+		return "", Invalid("option %s needs one of %v", schema.Key, schema.Values)
+	case model.OptionTypeColor:
+		if !colorRegex.MatchString(value) {
+			return "", Invalid("option %s needs a color like #a1b2c3", schema.Key)
+		}
+		return value, nil
+	}
+	if len(value) > maxOptionLength {
+		return "", Invalid("option %s exceeds %d characters", schema.Key, maxOptionLength)
+	}
+	return value, nil
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// A stored media file which may be referenced by several posts
+type Blob struct {
+	Hash      string    `json:"hash"`
+	Path      string    `json:"path"`
+	Type      int       `json:"type"`
+	Size      int64     `json:"size"`
+	RefCount  int       `json:"ref_count" db:"ref_count"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// The name of the collection every user has for saved posts
+const DefaultCollection = "saved"
+
+// A personal collection of posts, only visible to its owner
+//
+// swagger:model
+type Collection struct {
+	ID        int       `json:"-"`
+	UniqueID  string    `json:"unique_id" db:"unique_id"`
+	UserID    int       `json:"-" db:"user_id"`
+	Name      string    `json:"name"`
+	Default   bool      `json:"default" db:"is_default"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	Posts     []*Post   `json:"posts,omitempty" db:"-"`
+}
+
+// swagger:parameters createCollection
+type CreateCollectionRequestBody struct {
+	// in: body
+	Info struct {
+		Name string `json:"name"`
+	}
+}
+
+// swagger:parameters getCollection removeCollection
+type CollectionUidParams struct {
+	// required: true
+	// in: query
+	CollectionUid string `json:"collection_uid"`
+}
+
+// swagger:parameters collectPost uncollectPost
+type CollectPostParams struct {
+	// required: true
+	// in: query
+	PostUid string `json:"post_uid"`
+
+	// The collection, the default collection is used if omitted
+	//
+	// in: query
+	CollectionUid string `json:"collection_uid"`
+}
+
+// swagger:parameters userInfo
+type UserInfoParams struct {
+	// Include the collections of the user together with their posts
+	//
+	// in: query
+	Collections bool `json:"collections"`
+}
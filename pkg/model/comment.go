@@ -0,0 +1,62 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// A comment on a post, replies are nested below the comment they answer
+//
+// swagger:model
+type Comment struct {
+	ID        int           `json:"-"`
+	UniqueID  string        `json:"unique_id" db:"unique_id"`
+	PostID    int           `json:"-" db:"post_id"`
+	UserID    int           `json:"-" db:"user_id"`
+	ParentID  sql.NullInt32 `json:"-" db:"parent_id"`
+	Author    string        `json:"author"`
+	Content   string        `json:"content"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at" db:"updated_at"`
+	Replies   []*Comment    `json:"replies" db:"-"`
+}
+
+// swagger:parameters createComment
+type CreateCommentRequestBody struct {
+	// required: true
+	// in: query
+	PostUid string `json:"post_uid"`
+
+	// in: body
+	Info struct {
+		Content string `json:"content"`
+		// The comment this comment replies to
+		ParentUid string `json:"parent_uid"`
+	}
+}
+
+// swagger:parameters editComment
+type EditCommentRequestBody struct {
+	// required: true
+	// in: query
+	CommentUid string `json:"comment_uid"`
+
+	// in: body
+	Info struct {
+		Content string `json:"content"`
+	}
+}
+
+// swagger:parameters getComments
+type GetCommentsParams struct {
+	// required: true
+	// in: query
+	PostUid string `json:"post_uid"`
+}
+
+// swagger:parameters removeComment
+type RemoveCommentParams struct {
+	// required: true
+	// in: query
+	CommentUid string `json:"comment_uid"`
+}
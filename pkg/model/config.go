@@ -1,34 +1,83 @@
 package model
 
 type Config struct {
-	RunLevel                      string `json:"run_level"`
-	LoggingLevel                  string `json:"logging_level"`
-	JwtSecret                     string `json:"jwt_secret"`
-	ServerAddress                 string `json:"server_address"`
-	ServerPort                    string `json:"server_port"`
-	Database                      string `json:"database"`
-	DatabaseUser                  string `json:"database_user"`
-	DatabasePassword              string `json:"database_password"`
-	DatabaseAddress               string `json:"database_address"`
-	DatabasePort                  string `json:"database_port"`
-	AccessControlAllowOrigin      string `json:"access_control_allow_origin"`
-	AccessControlAllowCredentials string `json:"access_control_allow_credentials"`
-	AccessControlAllowMethods     string `json:"access_control_allow_methods"`
-	AccessControlAllowHeaders     string `json:"access_control_allow_headers"`
-	Swaggerfile                   string `json:"swagger_file"`
-	ApiKey                        string `json:"api_key"`
-	MaxImageSize                  int64  `json:"max_image_size"`
-	MaxVideoSize                  int64  `json:"max_video_size"`
-	ImagePath                     string `json:"image_path"`
-	VideoPath                     string `json:"video_path"`
+	RunLevel                      string           `json:"run_level"`
+	LoggingLevel                  string           `json:"logging_level"`
+	JwtSecret                     string           `json:"jwt_secret"`
+	ServerAddress                 string           `json:"server_address"`
+	ServerPort                    string           `json:"server_port"`
+	Database                      string           `json:"database"`
+	DatabaseUser                  string           `json:"database_user"`
+	DatabasePassword              string           `json:"database_password"`
+	DatabaseAddress               string           `json:"database_address"`
+	DatabasePort                  string           `json:"database_port"`
+	AccessControlAllowOrigin      string           `json:"access_control_allow_origin"`
+	AccessControlAllowCredentials string           `json:"access_control_allow_credentials"`
+	AccessControlAllowMethods     string           `json:"access_control_allow_methods"`
+	AccessControlAllowHeaders     string           `json:"access_control_allow_headers"`
+	Swaggerfile                   string           `json:"swagger_file"`
+	ApiKey                        string           `json:"api_key"`
+	MaxImageSize                  int64            `json:"max_image_size"`
+	MaxVideoSize                  int64            `json:"max_video_size"`
+	ImagePath                     string           `json:"image_path"`
+	VideoPath                     string           `json:"video_path"`
+	MaxAudioSize                  int64            `json:"max_audio_size"`
+	MaxTextSize                   int64            `json:"max_text_size"`
+	MaxSketchSize                 int64            `json:"max_sketch_size"`
+	AudioPath                     string           `json:"audio_path"`
+	MaxPostMedia                  int              `json:"max_post_media"`
+	UploadPath                    string           `json:"upload_path"`
+	MaxChunkSize                  int64            `json:"max_chunk_size"`
+	UploadExpiry                  int64            `json:"upload_expiry"`
+	SweepInterval                 int64            `json:"sweep_interval"`
+	SweepGracePeriod              int64            `json:"sweep_grace_period"`
+	UserQuota                     int64            `json:"user_quota"`
+	GroupQuota                    int64            `json:"group_quota"`
+	GroupQuotas                   map[string]int64 `json:"group_quotas"`
+	MediaSecret                   string           `json:"media_secret"`
+	MediaUrlExpiry                int64            `json:"media_url_expiry"`
+	SessionRoundDuration          int64            `json:"session_round_duration"`
+	SessionTick                   int64            `json:"session_tick"`
+	VotesPerUser                  int              `json:"votes_per_user"`
+	Reactions                     []string         `json:"reactions"`
+	EventLogSize                  int              `json:"event_log_size"`
+	PushFake                      bool             `json:"push_fake"`
+	PushRetries                   int              `json:"push_retries"`
+	FcmCredentialsFile            string           `json:"fcm_credentials_file"`
+	FcmEndpoint                   string           `json:"fcm_endpoint"`
+	ApnsKeyFile                   string           `json:"apns_key_file"`
+	ApnsKeyID                     string           `json:"apns_key_id"`
+	ApnsTeamID                    string           `json:"apns_team_id"`
+	ApnsTopic                     string           `json:"apns_topic"`
+	ApnsEndpoint                  string           `json:"apns_endpoint"`
+	WebhookInterval               int64            `json:"webhook_interval"`
+	WebhookMaxAttempts            int              `json:"webhook_max_attempts"`
+	WebhookAllowedHosts           []string         `json:"webhook_allowed_hosts"`
 }
 
 // A response model for the config endpoint
 //
 // swagger:model
 type Configuration struct {
-	MaxImageSize int64  `json:"max_image_size"`
-	MaxVideoSize int64  `json:"max_video_size"`
-	ImagePath    string `json:"image_path"`
-	VideoPath    string `json:"video_path"`
+	MaxImageSize  int64  `json:"max_image_size"`
+	MaxVideoSize  int64  `json:"max_video_size"`
+	ImagePath     string `json:"image_path"`
+	VideoPath     string `json:"video_path"`
+	MaxAudioSize  int64  `json:"max_audio_size"`
+	AudioPath     string `json:"audio_path"`
+	MaxTextSize   int64  `json:"max_text_size"`
+	MaxSketchSize int64  `json:"max_sketch_size"`
+	MaxPostMedia  int    `json:"max_post_media"`
+	UserQuota     int64  `json:"user_quota"`
+	GroupQuota    int64  `json:"group_quota"`
+	// The kinds of reactions posts accept
+	Reactions []string `json:"reactions"`
+}
+
+// The storage usage of a user or group in bytes, a quota of 0 means unlimited
+//
+// swagger:model
+type StorageUsage struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"`
 }
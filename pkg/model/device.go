@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// The push services devices register with
+const (
+	PlatformFcm  = "fcm"
+	PlatformApns = "apns"
+)
+
+// A device of a user, which receives push notifications
+//
+// swagger:model
+type Device struct {
+	ID        int       `json:"-"`
+	UserID    int       `json:"-" db:"user_id"`
+	Platform  string    `json:"platform"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// swagger:parameters registerDevice
+type RegisterDeviceRequestBody struct {
+	// The push service of the device, fcm or apns
+	//
+	// in: body
+	Device *Device
+}
+
+// swagger:parameters unregisterDevice
+type UnregisterDeviceParams struct {
+	// required: true
+	// in: query
+	Token string `json:"token"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// The types of events emitted by the handlers
+const (
+	EventPostCreated    = "post_created"
+	EventPostRemoved    = "post_removed"
+	EventOptionsChanged = "options_changed"
+	EventCommentCreated = "comment_created"
+	EventMention        = "mention"
+	EventMemberAdded    = "member_added"
+	EventVoteCast       = "vote_cast"
+	EventVotingClosed   = "voting_closed"
+	EventSessionStarted = "session_started"
+)
+
+// EventTypes lists all types of events
+var EventTypes = []string{
+	EventPostCreated,
+	EventPostRemoved,
+	EventOptionsChanged,
+	EventCommentCreated,
+	EventMention,
+	EventMemberAdded,
+	EventVoteCast,
+	EventVotingClosed,
+	EventSessionStarted,
+}
+
+// Something that happened in a group or on a post. Recipients are the users
+// who are personally concerned by the event and get notified about it.
+//
+// swagger:model
+type Event struct {
+	Type       string        `json:"type"`
+	ActorID    int           `json:"-"`
+	Actor      string        `json:"actor"`
+	GroupID    sql.NullInt32 `json:"-"`
+	GroupUid   string        `json:"group_uid,omitempty"`
+	PostUid    string        `json:"post_uid,omitempty"`
+	ParentUid  string        `json:"parent_uid,omitempty"`
+	Recipients []int         `json:"-"`
+	Data       interface{}   `json:"data,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
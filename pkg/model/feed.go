@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+// The types of feed items
+const (
+	FeedItemPost    = "post"
+	FeedItemComment = "comment"
+	FeedItemMember  = "member"
+)
+
+// A page of the activity feed, the cursor is empty on the last page
+//
+// swagger:model
+type Feed struct {
+	Items  []*FeedItem `json:"items"`
+	Cursor string      `json:"cursor,omitempty"`
+}
+
+// An entry of the activity feed, depending on the type one of post,
+// comment and member is set
+//
+// swagger:model
+type FeedItem struct {
+	Type      string       `json:"type"`
+	ID        int          `json:"-"`
+	GroupUid  string       `json:"group_uid"`
+	Post      *Post        `json:"post,omitempty"`
+	Comment   *FeedComment `json:"comment,omitempty"`
+	Member    *Membership  `json:"member,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// A comment together with the post and group it belongs to
+//
+// swagger:model
+type FeedComment struct {
+	Comment
+	PostUid  string `json:"post_uid" db:"post_uid"`
+	GroupUid string `json:"group_uid" db:"group_uid"`
+}
+
+// The joining of a user to a group
+//
+// swagger:model
+type Membership struct {
+	ID        int       `json:"-"`
+	GroupID   int       `json:"-" db:"group_id"`
+	GroupUid  string    `json:"group_uid" db:"group_uid"`
+	UserID    int       `json:"-" db:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// A position in the feed, only items created before it or at the same time
+// with a lower id are selected
+type FeedCursor struct {
+	Time time.Time
+	ID   int
+}
+
+// swagger:parameters feed
+type FeedParams struct {
+	// The cursor of the previous page
+	//
+	// in: query
+	Cursor string `json:"cursor"`
+
+	// in: query
+	Limit uint64 `json:"limit"`
+}
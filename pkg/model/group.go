@@ -11,6 +11,14 @@ type Group struct {
 	Public   bool   `json:"public"`
 }
 
+// A group with its current storage usage
+//
+// swagger:model
+type GroupWithUsage struct {
+	Group
+	Usage *StorageUsage `json:"usage"`
+}
+
 // swagger:model
 type UserGroupRelation struct {
 	Email string `json:"email"`
@@ -27,7 +35,7 @@ type AddUserToGroupRequestBody struct {
 	Relation *UserGroupRelation `json:"relation"`
 }
 
-// swagger:parameters listGroupMembers groupInfo joinGroup removeGroup
+// swagger:parameters listGroupMembers groupInfo joinGroup removeGroup listWebhooks
 type ListMembersParams struct {
 	// required: true
 	// in: query
@@ -0,0 +1,29 @@
+package model
+
+// Commands clients send over the live connection
+const (
+	LiveSubscribe   = "subscribe"
+	LiveUnsubscribe = "unsubscribe"
+)
+
+// A command of a live client, subscribing either to a group or to a post
+// and its children
+//
+// swagger:model
+type LiveCommand struct {
+	Action   string `json:"action"`
+	GroupUid string `json:"group_uid"`
+	PostUid  string `json:"post_uid"`
+}
+
+// The answer to a command of a live client, its type is the action or
+// "error" if the command failed. Subscriptions the user lost access to are
+// dropped by the server with an unsolicited "unsubscribe" reply.
+//
+// swagger:model
+type LiveReply struct {
+	Type     string `json:"type"`
+	GroupUid string `json:"group_uid,omitempty"`
+	PostUid  string `json:"post_uid,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
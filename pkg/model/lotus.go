@@ -0,0 +1,39 @@
+package model
+
+// A lotus blossom: the central theme surrounded by eight petals, each petal
+// is the theme of eight further petals
+//
+// swagger:model
+type LotusBlossom struct {
+	Center *Post `json:"center"`
+	// The eight petals around the center, sorted by position
+	Petals []*LotusPetal `json:"petals"`
+	// All cells which don't contain a post yet
+	Empty    []*LotusCell `json:"empty"`
+	Complete bool         `json:"complete"`
+}
+
+// swagger:model
+type LotusPetal struct {
+	Position int   `json:"position"`
+	Post     *Post `json:"post"`
+	// The outer petals of a petal, empty for outer petals themselves
+	Petals []*LotusPetal `json:"petals,omitempty"`
+}
+
+// A cell of the lotus blossom, petal is 0 for the petals around the center
+//
+// swagger:model
+type LotusCell struct {
+	Petal    int `json:"petal"`
+	Position int `json:"position"`
+}
+
+// swagger:parameters lotusBlossom
+type LotusBlossomParams struct {
+	// The unique id of the center post
+	//
+	// required: true
+	// in: query
+	UniqueID string `json:"uid"`
+}
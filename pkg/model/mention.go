@@ -0,0 +1,22 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// A mention of a user by @name in the title of a post or in a comment
+//
+// swagger:model
+type Mention struct {
+	ID         int           `json:"-"`
+	UserID     int           `json:"-" db:"user_id"`
+	AuthorID   int           `json:"-" db:"author_id"`
+	PostID     int           `json:"-" db:"post_id"`
+	CommentID  sql.NullInt32 `json:"-" db:"comment_id"`
+	GroupID    sql.NullInt32 `json:"-" db:"group_id"`
+	Author     string        `json:"author"`
+	PostUid    string        `json:"post_uid" db:"post_uid"`
+	CommentUid string        `json:"comment_uid" db:"comment_uid"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+}
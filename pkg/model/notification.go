@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// A notification of a user about an event concerning them
+//
+// swagger:model
+type Notification struct {
+	ID        int       `json:"-"`
+	UniqueID  string    `json:"unique_id" db:"unique_id"`
+	UserID    int       `json:"-" db:"user_id"`
+	Type      string    `json:"type"`
+	ActorID   int       `json:"-" db:"actor_id"`
+	Actor     string    `json:"actor"`
+	PostUid   string    `json:"post_uid" db:"post_uid"`
+	GroupUid  string    `json:"group_uid" db:"group_uid"`
+	Read      bool      `json:"read" db:"is_read"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Whether a user wants to be notified about a type of events, users are
+// notified about all types unless they disabled them
+//
+// swagger:model
+type NotificationPreference struct {
+	UserID  int    `json:"-" db:"user_id"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+// swagger:parameters listNotifications
+type ListNotificationsParams struct {
+	// Only return unread notifications
+	//
+	// in: query
+	Unread bool `json:"unread"`
+
+	// in: query
+	Limit uint64 `json:"limit"`
+}
+
+// swagger:parameters readNotification
+type ReadNotificationParams struct {
+	// required: true
+	// in: query
+	NotificationUid string `json:"notification_uid"`
+}
+
+// swagger:parameters setNotificationPreferences
+type SetNotificationPreferencesRequestBody struct {
+	// in: body
+	Preferences []*NotificationPreference
+}
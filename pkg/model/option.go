@@ -1,13 +1,84 @@
 package model
 
+import (
+	"encoding/json"
+	"strconv"
+)
+
+const (
+	OptionTypeString = "string"
+	OptionTypeInt    = "int"
+	OptionTypeBool   = "bool"
+	OptionTypeEnum   = "enum"
+	OptionTypeColor  = "color"
+)
+
+// An option of a post. The value is stored as string and returned with the
+// json type its schema declares.
+//
 // swagger:model
 type Option struct {
 	Key     string `json:"key" db:"opt_key"`
 	Value   string `json:"value" db:"opt_value"`
+	Type    string `json:"type,omitempty" db:"-"`
 	PostUid string `json:"-" db:"post_uid"`
 }
 
-// swagger:parameters setOptions AddOptions
+// MarshalJSON writes int and bool options as json numbers and booleans
+func (o *Option) MarshalJSON() ([]byte, error) {
+	var value interface{} = o.Value
+	switch o.Type {
+	case OptionTypeInt:
+		if i, err := strconv.ParseInt(o.Value, 10, 64); err == nil {
+			value = i
+		}
+	case OptionTypeBool:
+		if b, err := strconv.ParseBool(o.Value); err == nil {
+			value = b
+		}
+	}
+	return json.Marshal(struct {
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+		Type  string      `json:"type,omitempty"`
+	}{o.Key, value, o.Type})
+}
+
+// UnmarshalJSON accepts values as strings as well as typed json values
+func (o *Option) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	o.Key = raw.Key
+	o.Value = ""
+	if len(raw.Value) == 0 || string(raw.Value) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw.Value, &o.Value); err != nil {
+		o.Value = string(raw.Value)
+	}
+	return nil
+}
+
+// The declaration of an option posts of a creativity method may carry
+//
+// swagger:model
+type OptionSchema struct {
+	Key string `json:"key"`
+	// One of string, int, bool, enum or color (#rrggbb)
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+	// The allowed values of enum options
+	Values []string `json:"values,omitempty"`
+}
+
+// swagger:parameters setOptions AddOptions upsertOptions
 type AddOptionReqBody struct {
 	// in: query
 	// required: true
@@ -17,3 +88,14 @@ type AddOptionReqBody struct {
 	// required: true
 	Options []*Option `json:"options"`
 }
+
+// swagger:parameters removeOption
+type RemoveOptionParams struct {
+	// required: true
+	// in: query
+	UniqueID string `json:"uid"`
+
+	// required: true
+	// in: query
+	Key string `json:"key"`
+}
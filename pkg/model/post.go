@@ -9,32 +9,67 @@ import (
 const (
 	PostTypeImage = iota
 	PostTypeVideo
-)
-
-const (
-	Method101 = iota
-	MethodLotusBlossum
-	MethodScamper
+	PostTypeText
+	PostTypeAudio
+	PostTypeSketch
 )
 
 // A post request model
 //
 // swagger:model
 type Post struct {
-	ID        int           `json:"-"`
-	UniqueID  string        `json:"unique_id" db:"unique_id"`
-	Title     string        `json:"title"`
-	UserID    int           `json:"-" db:"user_id"`
-	Path      string        `json:"path"`
-	CreatedAt time.Time     `json:"created_at" db:"created_at"`
-	ParentID  sql.NullInt32 `json:"-" db:"parent_id"`
-	Method    int           `json:"method"`
-	Type      int           `json:"type"`
-	GroupID   sql.NullInt32 `json:"-" db:"group_id"`
-	Options   []*Option     `json:"options"`
-	ParentUid string        `json:"parent_uid" db:"parent_uid"`
-	GroupUid  string        `json:"group_uid" db:"group_uid"`
-	Username  string        `json:"user" db:"name"`
+	ID        int            `json:"-"`
+	UniqueID  string         `json:"unique_id" db:"unique_id"`
+	Title     string         `json:"title"`
+	UserID    int            `json:"-" db:"user_id"`
+	Path      string         `json:"path"`
+	Url       string         `json:"url" db:"-"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	ParentID  sql.NullInt32  `json:"-" db:"parent_id"`
+	Method    int            `json:"method"`
+	Type      int            `json:"type"`
+	GroupID   sql.NullInt32  `json:"-" db:"group_id"`
+	Size      int64          `json:"size"`
+	Content   string         `json:"content"`
+	Position  int            `json:"position"`
+	Prompt    string         `json:"prompt"`
+	Options   []*Option      `json:"options"`
+	Media     []*PostMedia   `json:"media"`
+	Reactions map[string]int `json:"reactions"`
+	ParentUid string         `json:"parent_uid" db:"parent_uid"`
+	GroupUid  string         `json:"group_uid" db:"group_uid"`
+	Username  string         `json:"user" db:"name"`
+}
+
+// A media file of a post, posts may contain an ordered list of files
+//
+// swagger:model
+type PostMedia struct {
+	ID       int    `json:"-"`
+	PostID   int    `json:"-" db:"post_id"`
+	Position int    `json:"position"`
+	Path     string `json:"path"`
+	Url      string `json:"url" db:"-"`
+	Type     int    `json:"type"`
+	Size     int64  `json:"size"`
+}
+
+// A vector sketch, the content of sketch posts
+//
+// swagger:model
+type Sketch struct {
+	Width   float64   `json:"width"`
+	Height  float64   `json:"height"`
+	Strokes []*Stroke `json:"strokes"`
+}
+
+// A single stroke of a sketch, points are x and y coordinates on the canvas
+//
+// swagger:model
+type Stroke struct {
+	Color  string       `json:"color"`
+	Width  float64      `json:"width"`
+	Points [][2]float64 `json:"points"`
 }
 
 type PostResponse struct {
@@ -95,17 +130,45 @@ type PostFileBodyParams struct {
 	// in: formData
 	ParentUID string `json:"parent_uid"`
 
+	// The id of the creativity method, see /methods. Child posts take the
+	// method of their root post.
+	//
 	// required: true
 	// in: formData
-	// enum: 0,1,2,3
 	Method int `json:"method"`
 
 	// required: true
 	// in: formData
-	// enum: 0,1
+	// enum: 0,1,2,3,4
 	Type int `json:"type"`
 
-	// required: true
+	// The text of text posts or the json encoded strokes of sketch posts
+	//
+	// in: formData
+	Content string `json:"content"`
+
+	// The cell of the post within structured methods, e.g. the petal
+	// of a lotus blossom from 1 to 8
+	//
+	// in: formData
+	Position int `json:"position"`
+
+	// The letter of the scamper prompt the post answers, posts of methods
+	// without prompts must leave it empty
+	//
+	// in: formData
+	// enum: S,C,A,M,P,E,R
+	Prompt string `json:"prompt"`
+
+	// The json encoded options of the post, e.g. [{"key": "k", "value": "v"}].
+	// Root posts have to carry the required options of their method.
+	//
+	// in: formData
+	Options string `json:"options"`
+
+	// One or more files, the first file is the cover of the post.
+	// Text and sketch posts are stored without a file.
+	//
 	// in: formData
 	// swagger:file
 	// name: file
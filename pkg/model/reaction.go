@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// A reaction of a user on a post, the kind is one of the configured reactions
+//
+// swagger:model
+type Reaction struct {
+	PostID    int       `json:"-" db:"post_id"`
+	UserID    int       `json:"-" db:"user_id"`
+	User      string    `json:"user"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// The number of reactions of one kind on a post
+type ReactionCount struct {
+	PostID int    `db:"post_id"`
+	Kind   string `db:"kind"`
+	Count  int    `db:"count"`
+}
+
+// swagger:parameters react unreact
+type ReactParams struct {
+	// required: true
+	// in: query
+	PostUid string `json:"post_uid"`
+
+	// One of the reactions listed by the config endpoint
+	//
+	// required: true
+	// in: query
+	Kind string `json:"kind"`
+}
+
+// swagger:parameters listReactions
+type ListReactionsParams struct {
+	// required: true
+	// in: query
+	PostUid string `json:"post_uid"`
+}
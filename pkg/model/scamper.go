@@ -0,0 +1,49 @@
+package model
+
+// A prompt of the scamper method
+//
+// swagger:model
+type ScamperPrompt struct {
+	Letter   string `json:"letter"`
+	Name     string `json:"name"`
+	Question string `json:"question"`
+}
+
+// The seven prompts of the scamper method in their usual order
+var ScamperPrompts = []*ScamperPrompt{
+	{Letter: "S", Name: "Substitute", Question: "What can be substituted?"},
+	{Letter: "C", Name: "Combine", Question: "What can be combined with it?"},
+	{Letter: "A", Name: "Adapt", Question: "What can be adapted from elsewhere?"},
+	{Letter: "M", Name: "Modify", Question: "What can be magnified, minimized or modified?"},
+	{Letter: "P", Name: "Put to other use", Question: "How can it be put to other uses?"},
+	{Letter: "E", Name: "Eliminate", Question: "What can be eliminated?"},
+	{Letter: "R", Name: "Reverse", Question: "What can be reversed or rearranged?"},
+}
+
+// The posts of a scamper session answering one prompt
+//
+// swagger:model
+type ScamperSlot struct {
+	ScamperPrompt
+	Posts []*Post `json:"posts"`
+}
+
+// A scamper session with its seven slots and the prompts which haven't
+// been answered yet
+//
+// swagger:model
+type ScamperSession struct {
+	Root     *Post          `json:"root"`
+	Slots    []*ScamperSlot `json:"slots"`
+	Skipped  []string       `json:"skipped"`
+	Coverage float64        `json:"coverage"`
+}
+
+// swagger:parameters scamperSession
+type ScamperSessionParams struct {
+	// The unique id of the scamper root post
+	//
+	// required: true
+	// in: query
+	UniqueID string `json:"uid"`
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	SessionOpen = iota
+	SessionRunning
+	SessionClosed
+)
+
+// A round based session (e.g. 6-3-5) on the topic of a root post. In every
+// round each participant builds on the ideas of another participants sheet.
+//
+// swagger:model
+type Session struct {
+	ID            int           `json:"-"`
+	UniqueID      string        `json:"unique_id" db:"unique_id"`
+	PostID        int           `json:"-" db:"post_id"`
+	FacilitatorID int           `json:"-" db:"facilitator_id"`
+	GroupID       sql.NullInt32 `json:"-" db:"group_id"`
+	Method        int           `json:"method"`
+	// The duration of a round in seconds
+	RoundDuration int64 `json:"round_duration" db:"round_duration"`
+	// The number of rounds, 0 means one round per participant
+	Rounds        int        `json:"rounds"`
+	IdeasPerRound int        `json:"ideas_per_round" db:"ideas_per_round"`
+	CurrentRound  int        `json:"current_round" db:"current_round"`
+	RoundDeadline *time.Time `json:"round_deadline" db:"round_deadline"`
+	State         int        `json:"state"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// swagger:model
+type SessionParticipant struct {
+	SessionID int    `json:"-" db:"session_id"`
+	UserID    int    `json:"-" db:"user_id"`
+	Seat      int    `json:"seat"`
+	Name      string `json:"name"`
+}
+
+// A post created within a session round. The sheet is the seat of the
+// participant who started it, idea the column on the sheet.
+//
+// swagger:model
+type SessionContribution struct {
+	SessionID int    `json:"-" db:"session_id"`
+	PostID    int    `json:"-" db:"post_id"`
+	PostUid   string `json:"post_uid" db:"post_uid"`
+	UserID    int    `json:"-" db:"user_id"`
+	Round     int    `json:"round"`
+	Sheet     int    `json:"sheet"`
+	Idea      int    `json:"idea"`
+}
+
+// The sheet the current user works on in the current round and the posts
+// the ideas have to build on
+//
+// swagger:model
+type SessionAssignment struct {
+	Round    int        `json:"round"`
+	Sheet    int        `json:"sheet"`
+	Deadline *time.Time `json:"deadline"`
+	// The unique ids of the parent posts per idea, starting with idea 1
+	Parents []string `json:"parents"`
+	// The ideas the user already contributed in this round
+	Done []int `json:"done"`
+}
+
+// swagger:model
+type SessionInfo struct {
+	Session       *Session               `json:"session"`
+	Topic         *Post                  `json:"topic"`
+	Participants  []*SessionParticipant  `json:"participants"`
+	Contributions []*SessionContribution `json:"contributions"`
+	Assignment    *SessionAssignment     `json:"assignment"`
+}
+
+// swagger:parameters createSession
+type CreateSessionRequestBody struct {
+	// in: body
+	Info struct {
+		PostUid       string `json:"post_uid"`
+		RoundDuration int64  `json:"round_duration"`
+		Rounds        int    `json:"rounds"`
+		IdeasPerRound int    `json:"ideas_per_round"`
+	}
+}
+
+// swagger:parameters joinSession startSession closeSession sessionInfo
+type SessionUidParams struct {
+	// required: true
+	// in: query
+	SessionUid string `json:"session_uid"`
+}
+
+// swagger:parameters contributeToSession
+type ContributeParams struct {
+	// required: true
+	// in: query
+	SessionUid string `json:"session_uid"`
+
+	// The column on the sheet, from 1 to ideas per round. If omitted,
+	// the first free idea of the round is used.
+	//
+	// in: formData
+	Idea int `json:"idea"`
+
+	PostFileBodyParams
+}
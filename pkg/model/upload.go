@@ -0,0 +1,97 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// A resumable upload which is transferred in several chunks
+//
+// swagger:model
+type Upload struct {
+	ID         int           `json:"-"`
+	UniqueID   string        `json:"unique_id" db:"unique_id"`
+	UserID     int           `json:"-" db:"user_id"`
+	GroupID    sql.NullInt32 `json:"-" db:"group_id"`
+	ParentID   sql.NullInt32 `json:"-" db:"parent_id"`
+	Title      string        `json:"title"`
+	Method     int           `json:"method"`
+	Type       int           `json:"type"`
+	Position   int           `json:"position"`
+	Prompt     string        `json:"prompt"`
+	Options    string        `json:"-"`
+	Extension  string        `json:"-"`
+	Size       int64         `json:"size"`
+	Received   int64         `json:"offset"`
+	Completing bool          `json:"completing"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+}
+
+// swagger:parameters initUpload
+type InitUploadParams struct {
+	// in: query
+	GroupUid string `json:"group_uid"`
+
+	// in: formData
+	Title string `json:"title"`
+
+	// in: formData
+	ParentUID string `json:"parent_uid"`
+
+	// required: true
+	// in: formData
+	Method int `json:"method"`
+
+	// required: true
+	// in: formData
+	// enum: 0,1,3
+	Type int `json:"type"`
+
+	// in: formData
+	Position int `json:"position"`
+
+	// in: formData
+	Prompt string `json:"prompt"`
+
+	// The json encoded options of the post, see the post upload
+	//
+	// in: formData
+	Options string `json:"options"`
+
+	// The total size of the file in bytes
+	//
+	// required: true
+	// in: formData
+	Size int64 `json:"size"`
+
+	// The mime type of the file, e.g. video/mp4
+	//
+	// required: true
+	// in: formData
+	ContentType string `json:"content_type"`
+}
+
+// swagger:parameters uploadStatus completeUpload
+type UploadUidParams struct {
+	// required: true
+	// in: query
+	UploadUid string `json:"upload_uid"`
+}
+
+// swagger:parameters uploadChunk
+type UploadChunkParams struct {
+	// required: true
+	// in: query
+	UploadUid string `json:"upload_uid"`
+
+	// The byte offset the chunk starts at, has to match the current offset
+	//
+	// required: true
+	// in: query
+	Offset int64 `json:"offset"`
+
+	// The raw chunk data
+	//
+	// in: body
+	Chunk []byte `json:"chunk"`
+}
@@ -18,8 +18,11 @@ type User struct {
 // swagger:model
 type UserWithPostsGroups struct {
 	User
-	Groups []*Group `json:"groups"`
-	Posts  []*Post  `json:"posts"`
+	Groups []*Group      `json:"groups"`
+	Posts  []*Post       `json:"posts"`
+	Usage  *StorageUsage `json:"usage"`
+	// Only included if requested
+	Collections []*Collection `json:"collections,omitempty"`
 }
 
 // An user request model
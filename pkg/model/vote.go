@@ -0,0 +1,113 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	// Every participant distributes a number of dots on the ideas
+	VotingDots = iota
+	// Every participant rates the ideas from 1 to 5
+	VotingRating
+)
+
+const (
+	VotingOpen = iota
+	VotingClosed
+)
+
+// The highest rating of rating votings
+const MaxRating = 5
+
+// A voting on the child posts of a post or the root posts of a group. The
+// results are hidden until the facilitator closes the voting.
+//
+// swagger:model
+type Voting struct {
+	ID            int           `json:"-"`
+	UniqueID      string        `json:"unique_id" db:"unique_id"`
+	PostID        sql.NullInt32 `json:"-" db:"post_id"`
+	GroupID       sql.NullInt32 `json:"-" db:"group_id"`
+	FacilitatorID int           `json:"-" db:"facilitator_id"`
+	Mode          int           `json:"mode"`
+	// The number of dots every participant may distribute in dot votings
+	VotesPerUser int       `json:"votes_per_user" db:"votes_per_user"`
+	State        int       `json:"state"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// The dots or the rating a user gave a post
+//
+// swagger:model
+type Vote struct {
+	VotingID int    `json:"-" db:"voting_id"`
+	UserID   int    `json:"-" db:"user_id"`
+	PostID   int    `json:"-" db:"post_id"`
+	PostUid  string `json:"post_uid" db:"post_uid"`
+	Value    int    `json:"value"`
+}
+
+// The summed up votes of a post
+type VoteTally struct {
+	PostID int `db:"post_id"`
+	Total  int `db:"total"`
+	Voters int `db:"voters"`
+}
+
+// swagger:model
+type VoteResult struct {
+	Rank int   `json:"rank"`
+	Post *Post `json:"post"`
+	// The number of dots, or the average rating
+	Score  float64 `json:"score"`
+	Voters int     `json:"voters"`
+}
+
+// The state of a voting for the current user. The ranking is only included
+// after the voting was closed.
+//
+// swagger:model
+type VotingResults struct {
+	Voting    *Voting       `json:"voting"`
+	Votes     []*Vote       `json:"votes"`
+	Remaining int           `json:"remaining"`
+	Ranking   []*VoteResult `json:"ranking"`
+}
+
+// swagger:parameters createVoting
+type CreateVotingRequestBody struct {
+	// in: body
+	Info struct {
+		// The post whose child posts are voted on
+		PostUid string `json:"post_uid"`
+		// The group whose root posts are voted on, if no post is given
+		GroupUid string `json:"group_uid"`
+		// 0: dot voting, 1: rating from 1 to 5
+		Mode         int `json:"mode"`
+		VotesPerUser int `json:"votes_per_user"`
+	}
+}
+
+// swagger:parameters votingResults closeVoting
+type VotingUidParams struct {
+	// required: true
+	// in: query
+	VotingUid string `json:"voting_uid"`
+}
+
+// swagger:parameters castVote retractVote
+type CastVoteParams struct {
+	// required: true
+	// in: query
+	VotingUid string `json:"voting_uid"`
+
+	// required: true
+	// in: query
+	PostUid string `json:"post_uid"`
+
+	// The dots for the post, or the rating from 1 to 5. Omitted when retracting.
+	//
+	// in: query
+	Value int `json:"value"`
+}
@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+const (
+	DeliveryPending = iota
+	DeliveryDelivered
+	DeliveryFailed
+)
+
+// The event type of test deliveries
+const EventPing = "ping"
+
+// A URL of a group receiving the selected types of its events
+//
+// swagger:model
+type Webhook struct {
+	ID       int    `json:"-"`
+	UniqueID string `json:"unique_id" db:"unique_id"`
+	GroupID  int    `json:"-" db:"group_id"`
+	Url      string `json:"url"`
+	// The key the payloads are signed with, only returned on creation
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events" db:"-"`
+	EventList string    `json:"-" db:"events"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// An attempted or pending delivery of an event to a webhook
+//
+// swagger:model
+type WebhookDelivery struct {
+	ID          int        `json:"-"`
+	UniqueID    string     `json:"unique_id" db:"unique_id"`
+	WebhookID   int        `json:"-" db:"webhook_id"`
+	EventType   string     `json:"event_type" db:"event_type"`
+	Payload     string     `json:"payload"`
+	State       int        `json:"state"`
+	Attempts    int        `json:"attempts"`
+	NextAttempt time.Time  `json:"next_attempt" db:"next_attempt"`
+	LastStatus  int        `json:"last_status" db:"last_status"`
+	LastError   string     `json:"last_error" db:"last_error"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at" db:"delivered_at"`
+	Url         string     `json:"-" db:"url"`
+	Secret      string     `json:"-" db:"secret"`
+}
+
+// swagger:parameters createWebhook
+type CreateWebhookRequestBody struct {
+	// required: true
+	// in: query
+	GroupUid string `json:"group_uid"`
+
+	// in: body
+	Info struct {
+		Url    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+}
+
+// swagger:parameters removeWebhook testWebhook webhookDeliveries
+type WebhookUidParams struct {
+	// required: true
+	// in: query
+	GroupUid string `json:"group_uid"`
+
+	// required: true
+	// in: query
+	WebhookUid string `json:"webhook_uid"`
+}
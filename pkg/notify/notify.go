@@ -0,0 +1,95 @@
+// Package notify turns events into notifications of the users concerned by
+// them, respecting which types of events the users want to be notified about.
+package notify
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type repository interface {
+	Persist(ctx context.Context, notification *model.Notification) error
+	IsEnabled(ctx context.Context, userID int, kind string) (bool, error)
+}
+
+// Notifier persists a notification for every recipient of an event. Events
+// are queued, so publishing requests are not held up by the database.
+type Notifier struct {
+	repo  repository
+	queue chan *model.Event
+	log   *logrus.Logger
+}
+
+func NewNotifier(repo repository, log *logrus.Logger) *Notifier {
+	return &Notifier{
+		repo:  repo,
+		queue: make(chan *model.Event, 256),
+		log:   log,
+	}
+}
+
+// Notify queues the event, it is meant to be subscribed to the event bus.
+// Events are dropped if the queue is full.
+func (n *Notifier) Notify(e *model.Event) {
+	if len(e.Recipients) == 0 {
+		return
+	}
+	select {
+	case n.queue <- e:
+	default:
+		n.log.WithField("type", e.Type).Warnln("notification queue is full, dropping event")
+	}
+}
+
+// Run stores the notifications of the queued events until the context is
+// done
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-n.queue:
+			n.store(ctx, e)
+		}
+	}
+}
+
+// store persists the notifications of the event. Actors are never notified
+// about their own events.
+func (n *Notifier) store(ctx context.Context, e *model.Event) {
+	notified := map[int]bool{e.ActorID: true}
+	for _, recipient := range e.Recipients {
+		if notified[recipient] {
+			continue
+		}
+		notified[recipient] = true
+		if err := n.notify(ctx, e, recipient); err != nil {
+			n.log.WithFields(logrus.Fields{
+				"type": e.Type, "recipient": recipient,
+			}).WithError(err).Errorln("notifying user failed")
+		}
+	}
+}
+
+func (n *Notifier) notify(ctx context.Context, e *model.Event, recipient int) error {
+	enabled, err := n.repo.IsEnabled(ctx, recipient, e.Type)
+	if err != nil || !enabled {
+		return err
+	}
+	uid, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	return n.repo.Persist(ctx, &model.Notification{
+		UniqueID: uid.String(),
+		UserID:   recipient,
+		Type:     e.Type,
+		ActorID:  e.ActorID,
+		PostUid:  e.PostUid,
+		GroupUid: e.GroupUid,
+	})
+}
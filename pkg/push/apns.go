@@ -0,0 +1,156 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const defaultApnsEndpoint = "https://api.push.apple.com"
+
+// Apple rejects provider tokens older than an hour
+const apnsTokenLifetime = 50 * time.Minute
+
+// APNs sends messages through the HTTP/2 API of the Apple Push Notification
+// service, authenticating with a token signed by the key of the team
+type APNs struct {
+	endpoint string
+	topic    string
+	keyID    string
+	teamID   string
+	key      *ecdsa.PrivateKey
+	client   *http.Client
+
+	lock   sync.Mutex
+	token  string
+	issued time.Time
+}
+
+// NewAPNs creates a provider for the app with the bundle id topic. keyFile
+// is the .p8 key downloaded from Apple, the endpoint defaults to the
+// production service.
+func NewAPNs(keyFile, keyID, teamID, topic, endpoint string) (*APNs, error) {
+	raw, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("apns: key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("apns: key is not an ECDSA key")
+	}
+	if endpoint == "" {
+		endpoint = defaultApnsEndpoint
+	}
+	return &APNs{
+		endpoint: endpoint,
+		topic:    topic,
+		keyID:    keyID,
+		teamID:   teamID,
+		key:      key,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{ForceAttemptHTTP2: true},
+		},
+	}, nil
+}
+
+type apnsAlert struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type apnsResponse struct {
+	Reason string `json:"reason"`
+}
+
+func (a *APNs) Send(ctx context.Context, token string, msg *Message) error {
+	payload := map[string]interface{}{}
+	for k, v := range msg.Data {
+		payload[k] = v
+	}
+	payload["aps"] = map[string]interface{}{
+		"alert": &apnsAlert{Title: msg.Title, Body: msg.Body},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	bearer, err := a.providerToken()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("authorization", "bearer "+bearer)
+	req.Header.Set("apns-topic", a.topic)
+	req.Header.Set("apns-push-type", "alert")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return &RetryableError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	result := &apnsResponse{}
+	json.NewDecoder(resp.Body).Decode(result)
+	err = fmt.Errorf("apns: status %d %s", resp.StatusCode, result.Reason)
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		result.Reason == "BadDeviceToken", result.Reason == "DeviceTokenNotForTopic":
+		return ErrInvalidToken
+	case result.Reason == "ExpiredProviderToken":
+		a.lock.Lock()
+		a.token = ""
+		a.lock.Unlock()
+		return &RetryableError{Err: err}
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= http.StatusInternalServerError:
+		return &RetryableError{Err: err}
+	default:
+		return err
+	}
+}
+
+// providerToken returns the signed token authenticating the requests, it is
+// reused until it is about to expire
+func (a *APNs) providerToken() (string, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.token != "" && time.Since(a.issued) < apnsTokenLifetime {
+		return a.token, nil
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, &jwt.StandardClaims{
+		Issuer:   a.teamID,
+		IssuedAt: now.Unix(),
+	})
+	token.Header["kid"] = a.keyID
+	signed, err := token.SignedString(a.key)
+	if err != nil {
+		return "", err
+	}
+	a.token = signed
+	a.issued = now
+	return signed, nil
+}
@@ -0,0 +1,81 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// A message sent by the fake provider
+type Delivery struct {
+	Token   string
+	Message *Message
+}
+
+// Fake is a provider for local setups and tests, which logs or records the
+// messages instead of sending them. Tokens can be marked invalid and
+// temporary failures simulated.
+type Fake struct {
+	lock       sync.Mutex
+	deliveries []*Delivery
+	record     bool
+	invalid    map[string]bool
+	failures   int
+	log        *logrus.Logger
+}
+
+// NewFake creates a fake provider for local setups, which only logs its
+// messages
+func NewFake(log *logrus.Logger) *Fake {
+	return &Fake{invalid: map[string]bool{}, log: log}
+}
+
+// NewRecordingFake creates a fake provider for tests, which keeps its
+// messages until they are fetched with Deliveries
+func NewRecordingFake() *Fake {
+	return &Fake{invalid: map[string]bool{}, record: true}
+}
+
+func (f *Fake) Send(ctx context.Context, token string, msg *Message) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.invalid[token] {
+		return ErrInvalidToken
+	}
+	if f.failures > 0 {
+		f.failures--
+		return &RetryableError{Err: errors.New("push: simulated failure")}
+	}
+	if f.record {
+		f.deliveries = append(f.deliveries, &Delivery{Token: token, Message: msg})
+	}
+	if f.log != nil {
+		f.log.WithFields(logrus.Fields{
+			"token": token, "title": msg.Title, "body": msg.Body,
+		}).Debugln("fake push delivered")
+	}
+	return nil
+}
+
+// Invalidate makes all following sends to the token fail with ErrInvalidToken
+func (f *Fake) Invalidate(token string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.invalid[token] = true
+}
+
+// Fail makes the next n sends fail with a RetryableError
+func (f *Fake) Fail(n int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.failures = n
+}
+
+// Deliveries returns the messages recorded so far
+func (f *Fake) Deliveries() []*Delivery {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]*Delivery{}, f.deliveries...)
+}
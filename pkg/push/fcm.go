@@ -0,0 +1,219 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const defaultFcmEndpoint = "https://fcm.googleapis.com"
+
+// The scope the access tokens are requested for
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// Google issues access tokens for an hour, they are renewed a bit earlier
+const fcmTokenMargin = 5 * time.Minute
+
+// FCM sends messages through the HTTP v1 API of Firebase Cloud Messaging,
+// authenticating with an OAuth2 access token of a service account
+type FCM struct {
+	endpoint  string
+	projectID string
+	email     string
+	tokenUri  string
+	key       *rsa.PrivateKey
+	client    *http.Client
+
+	lock    sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// The fields of the service account key file which are used
+type fcmCredentials struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenUri    string `json:"token_uri"`
+}
+
+// NewFCM creates a provider for the project of the service account.
+// credentialsFile is the JSON key of the account downloaded from the
+// Firebase console, the endpoint defaults to the one of Firebase.
+func NewFCM(credentialsFile, endpoint string) (*FCM, error) {
+	raw, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	credentials := &fcmCredentials{}
+	if err := json.Unmarshal(raw, credentials); err != nil {
+		return nil, err
+	}
+	if credentials.ProjectID == "" || credentials.ClientEmail == "" || credentials.TokenUri == "" {
+		return nil, errors.New("fcm: credentials miss the project, client email or token uri")
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(credentials.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == "" {
+		endpoint = defaultFcmEndpoint
+	}
+	return &FCM{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		projectID: credentials.ProjectID,
+		email:     credentials.ClientEmail,
+		tokenUri:  credentials.TokenUri,
+		key:       key,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+type fcmRequest struct {
+	Message fcmMessage `json:"message"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type fcmResponse struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// errorCode returns the FCM specific error code of the response, falling
+// back to the generic status
+func (r *fcmResponse) errorCode() string {
+	for _, detail := range r.Error.Details {
+		if detail.ErrorCode != "" {
+			return detail.ErrorCode
+		}
+	}
+	return r.Error.Status
+}
+
+type fcmTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (f *FCM) Send(ctx context.Context, token string, msg *Message) error {
+	body, err := json.Marshal(&fcmRequest{Message: fcmMessage{
+		Token:        token,
+		Notification: fcmNotification{Title: msg.Title, Body: msg.Body},
+		Data:         msg.Data,
+	}})
+	if err != nil {
+		return err
+	}
+	bearer, err := f.accessToken(ctx)
+	if err != nil {
+		return &RetryableError{Err: err}
+	}
+	target := f.endpoint + "/v1/projects/" + url.PathEscape(f.projectID) + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return &RetryableError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	result := &fcmResponse{}
+	json.NewDecoder(resp.Body).Decode(result)
+	code := result.errorCode()
+	err = fmt.Errorf("fcm: status %d %s %s", resp.StatusCode, code, result.Error.Message)
+	switch {
+	case code == "UNREGISTERED", code == "INVALID_ARGUMENT", code == "SENDER_ID_MISMATCH",
+		resp.StatusCode == http.StatusNotFound:
+		return ErrInvalidToken
+	case resp.StatusCode == http.StatusUnauthorized:
+		f.lock.Lock()
+		f.token = ""
+		f.lock.Unlock()
+		return &RetryableError{Err: err}
+	case code == "UNAVAILABLE", code == "INTERNAL", code == "QUOTA_EXCEEDED",
+		resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= http.StatusInternalServerError:
+		return &RetryableError{Err: err}
+	default:
+		return err
+	}
+}
+
+// accessToken returns the OAuth2 token authenticating the requests, it is
+// exchanged for a signed assertion of the service account and reused until
+// it is about to expire
+func (f *FCM) accessToken(ctx context.Context) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.token != "" && time.Now().Before(f.expires) {
+		return f.token, nil
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   f.email,
+		"scope": fcmScope,
+		"aud":   f.tokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(f.key)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.tokenUri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fcm: token request failed with status %d", resp.StatusCode)
+	}
+	result := &fcmTokenResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return "", err
+	}
+	if result.AccessToken == "" {
+		return "", errors.New("fcm: token response misses the access token")
+	}
+	f.token = result.AccessToken
+	f.expires = now.Add(time.Duration(result.ExpiresIn)*time.Second - fcmTokenMargin)
+	return f.token, nil
+}
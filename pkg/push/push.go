@@ -0,0 +1,194 @@
+// Package push delivers the notifications of the users to their phones.
+// Every platform a device registers with is served by a Provider, the
+// Dispatcher sends the events of the event bus to all devices of their
+// recipients.
+package push
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// ErrInvalidToken is returned by providers if the push service doesn't know
+// the device token anymore, the device is dropped then
+var ErrInvalidToken = errors.New("push: invalid device token")
+
+// RetryableError marks temporary failures of the push service, like rate
+// limits or server errors, which are worth retrying
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+// A push message as shown on the device
+type Message struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Provider sends messages to the devices of one push service
+type Provider interface {
+	Send(ctx context.Context, token string, msg *Message) error
+}
+
+type devices interface {
+	SelectByUser(ctx context.Context, userID int) ([]*model.Device, error)
+	Remove(ctx context.Context, device *model.Device) error
+}
+
+type preferences interface {
+	IsEnabled(ctx context.Context, userID int, kind string) (bool, error)
+}
+
+// Dispatcher pushes events to the devices of their recipients. Events are
+// queued, so publishing requests are not held up by the push services.
+type Dispatcher struct {
+	devices     devices
+	preferences preferences
+	providers   map[string]Provider
+	retries     int
+	backoff     time.Duration
+	queue       chan *model.Event
+	log         *logrus.Logger
+}
+
+// NewDispatcher creates a dispatcher sending to the providers by platform.
+// Failed deliveries are retried up to retries times with exponential
+// backoff.
+func NewDispatcher(devices devices, preferences preferences, providers map[string]Provider,
+	retries int, log *logrus.Logger) *Dispatcher {
+	return &Dispatcher{
+		devices:     devices,
+		preferences: preferences,
+		providers:   providers,
+		retries:     retries,
+		backoff:     time.Second,
+		queue:       make(chan *model.Event, 256),
+		log:         log,
+	}
+}
+
+// Notify queues the event for delivery, it is meant to be subscribed to the
+// event bus. Events are dropped if the queue is full.
+func (d *Dispatcher) Notify(e *model.Event) {
+	if len(e.Recipients) == 0 {
+		return
+	}
+	select {
+	case d.queue <- e:
+	default:
+		d.log.WithField("type", e.Type).Warnln("push queue is full, dropping event")
+	}
+}
+
+// Run delivers the queued events until the context is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-d.queue:
+			d.Dispatch(ctx, e)
+		}
+	}
+}
+
+// Dispatch sends the event to all devices of its recipients, who want to be
+// notified about it. Actors are never notified about their own events.
+func (d *Dispatcher) Dispatch(ctx context.Context, e *model.Event) {
+	msg := messageOf(e)
+	notified := map[int]bool{e.ActorID: true}
+	for _, recipient := range e.Recipients {
+		if notified[recipient] {
+			continue
+		}
+		notified[recipient] = true
+		enabled, err := d.preferences.IsEnabled(ctx, recipient, e.Type)
+		if err != nil {
+			d.log.WithField("recipient", recipient).WithError(err).Errorln("fetching push preference failed")
+			continue
+		}
+		if !enabled {
+			continue
+		}
+		devices, err := d.devices.SelectByUser(ctx, recipient)
+		if err != nil {
+			d.log.WithField("recipient", recipient).WithError(err).Errorln("fetching devices failed")
+			continue
+		}
+		for _, device := range devices {
+			d.deliver(ctx, device, msg)
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, device *model.Device, msg *Message) {
+	provider, ok := d.providers[device.Platform]
+	if !ok {
+		return
+	}
+	err := d.send(ctx, provider, device.Token, msg)
+	if err == ErrInvalidToken {
+		d.log.WithField("platform", device.Platform).Infoln("dropping invalid device token")
+		if err := d.devices.Remove(ctx, device); err != nil {
+			d.log.WithError(err).Errorln("removing device failed")
+		}
+		return
+	}
+	if err != nil {
+		d.log.WithField("platform", device.Platform).WithError(err).Errorln("push delivery failed")
+	}
+}
+
+// send tries to send the message, retrying temporary failures
+func (d *Dispatcher) send(ctx context.Context, provider Provider, token string, msg *Message) error {
+	backoff := d.backoff
+	for attempt := 0; ; attempt++ {
+		err := provider.Send(ctx, token, msg)
+		var retryable *RetryableError
+		if !errors.As(err, &retryable) || attempt >= d.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+var titles = map[string]string{
+	model.EventPostCreated:    "New idea on your post",
+	model.EventCommentCreated: "New comment",
+	model.EventMention:        "You were mentioned",
+	model.EventMemberAdded:    "New group member",
+	model.EventVotingClosed:   "Voting results are available",
+	model.EventSessionStarted: "A session started",
+}
+
+// messageOf describes the event for the notification center of the device
+func messageOf(e *model.Event) *Message {
+	title, ok := titles[e.Type]
+	if !ok {
+		title = "News from your groups"
+	}
+	return &Message{
+		Title: title,
+		Body:  e.Actor,
+		Data: map[string]string{
+			"type":      e.Type,
+			"post_uid":  e.PostUid,
+			"group_uid": e.GroupUid,
+		},
+	}
+}
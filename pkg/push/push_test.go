@@ -0,0 +1,146 @@
+package push
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type fakeDevices struct {
+	devices []*model.Device
+	removed []*model.Device
+}
+
+func (f *fakeDevices) SelectByUser(ctx context.Context, userID int) ([]*model.Device, error) {
+	devices := []*model.Device{}
+	for _, device := range f.devices {
+		if device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (f *fakeDevices) Remove(ctx context.Context, device *model.Device) error {
+	f.removed = append(f.removed, device)
+	return nil
+}
+
+// fakePreferences enables every type of events, besides the disabled ones
+// of a user
+type fakePreferences struct {
+	disabled map[int]string
+}
+
+func (f *fakePreferences) IsEnabled(ctx context.Context, userID int, kind string) (bool, error) {
+	return f.disabled[userID] != kind, nil
+}
+
+func newTestDispatcher(devices devices, preferences preferences, fake *Fake, retries int) *Dispatcher {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	d := NewDispatcher(devices, preferences, map[string]Provider{model.PlatformFcm: fake}, retries, log)
+	d.backoff = time.Millisecond
+	return d
+}
+
+func TestSendRetries(t *testing.T) {
+	fake := NewRecordingFake()
+	d := newTestDispatcher(&fakeDevices{}, &fakePreferences{}, fake, 3)
+	fake.Fail(3)
+
+	if err := d.send(context.Background(), fake, "token", &Message{Title: "title"}); err != nil {
+		t.Fatal(err)
+	}
+	deliveries := fake.Deliveries()
+	if len(deliveries) != 1 || deliveries[0].Token != "token" {
+		t.Errorf("unexpected deliveries %+v", deliveries)
+	}
+}
+
+func TestSendGivesUp(t *testing.T) {
+	fake := NewRecordingFake()
+	d := newTestDispatcher(&fakeDevices{}, &fakePreferences{}, fake, 2)
+	fake.Fail(4)
+
+	err := d.send(context.Background(), fake, "token", &Message{})
+	if _, ok := err.(*RetryableError); !ok {
+		t.Fatalf("send() = %v, want a retryable error", err)
+	}
+	if len(fake.Deliveries()) != 0 {
+		t.Fatal("message delivered despite failures")
+	}
+	// one failure is left, if the message was attempted retries + 1 times
+	if err := fake.Send(context.Background(), "token", &Message{}); err == nil {
+		t.Error("send attempted less than retries + 1 times")
+	}
+	if err := fake.Send(context.Background(), "token", &Message{}); err != nil {
+		t.Errorf("send attempted more than retries + 1 times: %v", err)
+	}
+}
+
+func TestSendDoesNotRetryInvalidToken(t *testing.T) {
+	fake := NewRecordingFake()
+	d := newTestDispatcher(&fakeDevices{}, &fakePreferences{}, fake, 3)
+	fake.Invalidate("token")
+
+	if err := d.send(context.Background(), fake, "token", &Message{}); err != ErrInvalidToken {
+		t.Errorf("send() = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestDispatchRemovesInvalidDevices(t *testing.T) {
+	valid := &model.Device{ID: 1, UserID: 2, Platform: model.PlatformFcm, Token: "valid"}
+	invalid := &model.Device{ID: 2, UserID: 2, Platform: model.PlatformFcm, Token: "invalid"}
+	devices := &fakeDevices{devices: []*model.Device{valid, invalid}}
+	fake := NewRecordingFake()
+	fake.Invalidate("invalid")
+	d := newTestDispatcher(devices, &fakePreferences{}, fake, 3)
+
+	d.Dispatch(context.Background(), &model.Event{
+		Type:       model.EventCommentCreated,
+		ActorID:    1,
+		Recipients: []int{2},
+	})
+	if len(devices.removed) != 1 || devices.removed[0] != invalid {
+		t.Errorf("removed devices %+v, want the invalid one", devices.removed)
+	}
+	deliveries := fake.Deliveries()
+	if len(deliveries) != 1 || deliveries[0].Token != "valid" {
+		t.Errorf("unexpected deliveries %+v", deliveries)
+	}
+}
+
+func TestDispatchSkipsActorAndDisabled(t *testing.T) {
+	devices := &fakeDevices{devices: []*model.Device{
+		{ID: 1, UserID: 1, Platform: model.PlatformFcm, Token: "actor"},
+		{ID: 2, UserID: 2, Platform: model.PlatformFcm, Token: "disabled"},
+		{ID: 3, UserID: 3, Platform: model.PlatformFcm, Token: "enabled"},
+		{ID: 4, UserID: 3, Platform: model.PlatformApns, Token: "unknown platform"},
+	}}
+	preferences := &fakePreferences{disabled: map[int]string{2: model.EventCommentCreated}}
+	fake := NewRecordingFake()
+	d := newTestDispatcher(devices, preferences, fake, 3)
+
+	d.Dispatch(context.Background(), &model.Event{
+		Type:       model.EventCommentCreated,
+		ActorID:    1,
+		Actor:      "actor",
+		Recipients: []int{1, 2, 3, 3},
+	})
+	deliveries := fake.Deliveries()
+	if len(deliveries) != 1 || deliveries[0].Token != "enabled" {
+		t.Fatalf("unexpected deliveries %+v", deliveries)
+	}
+	if msg := deliveries[0].Message; msg.Title != titles[model.EventCommentCreated] || msg.Body != "actor" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if len(devices.removed) != 0 {
+		t.Errorf("removed devices %+v", devices.removed)
+	}
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type blobRepository struct {
+	db                 *sqlx.DB
+	persist            *sqlx.Stmt
+	acquire            *sqlx.Stmt
+	getByPath          *sqlx.Stmt
+	updateRef          *sqlx.Stmt
+	decrementRef       *sqlx.Stmt
+	remove             *sqlx.Stmt
+	removeUnreferenced *sqlx.Stmt
+	selectAll          *sqlx.Stmt
+	selectRef          *sqlx.Stmt
+}
+
+func NewBlobRepository(db *sqlx.DB) (*blobRepository, error) {
+	ctx := context.Background()
+	persist, _ := sqlz.Newx(db).InsertInto("blobs").Columns("hash", "path", "type",
+		"size", "ref_count").Values("?", "?", "?", "?", "?").ToSQL(false)
+
+	acquire := persist + " ON DUPLICATE KEY UPDATE ref_count = ref_count + 1"
+
+	getByPath, _ := sqlz.Newx(db).Select("*").From("blobs").
+		Where(sqlz.Eq("type", "?"), sqlz.Eq("path", "?")).ToSQL(false)
+
+	updateRef, _ := sqlz.Newx(db).Update("blobs").Set("ref_count", "?").
+		Where(sqlz.Eq("type", "?"), sqlz.Eq("path", "?"), sqlz.Eq("ref_count", "?")).ToSQL(false)
+
+	decrementRef, _ := sqlz.Newx(db).Update("blobs").Set("ref_count", sqlz.Indirect("ref_count - 1")).
+		Where(sqlz.Eq("type", "?"), sqlz.Eq("path", "?")).ToSQL(false)
+
+	remove, _ := sqlz.Newx(db).DeleteFrom("blobs").
+		Where(sqlz.Eq("type", "?"), sqlz.Eq("path", "?"), sqlz.Eq("ref_count", "?")).ToSQL(false)
+
+	removeUnreferenced, _ := sqlz.Newx(db).DeleteFrom("blobs").
+		Where(sqlz.Eq("type", "?"), sqlz.Eq("path", "?"), sqlz.Lte("ref_count", sqlz.Indirect("0"))).ToSQL(false)
+
+	selectAll, _ := sqlz.Newx(db).Select("*").From("blobs").ToSQL(false)
+
+	// posts stored before media lists existed only reference their path,
+	// every other post references the files of its media list
+	refType, refPath := "COALESCE(m.type, p.type)", "COALESCE(m.path, p.path)"
+	selectRef, _ := sqlz.Newx(db).Select(refType+" AS type", refPath+" AS path", "COUNT(*) AS ref_count").
+		From("posts p").LeftJoin("post_media m", sqlz.Eq("m.post_id", sqlz.Indirect("p.id"))).
+		Where(sqlz.Ne(refPath, sqlz.Indirect("''"))).GroupBy(refType, refPath).ToSQL(false)
+
+	ctxPersist, err := db.PreparexContext(ctx, persist)
+	if err != nil {
+		return nil, err
+	}
+	ctxAcquire, err := db.PreparexContext(ctx, acquire)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetByPath, err := db.PreparexContext(ctx, getByPath)
+	if err != nil {
+		return nil, err
+	}
+	ctxUpdateRef, err := db.PreparexContext(ctx, updateRef)
+	if err != nil {
+		return nil, err
+	}
+	ctxDecrementRef, err := db.PreparexContext(ctx, decrementRef)
+	if err != nil {
+		return nil, err
+	}
+	ctxRemove, err := db.PreparexContext(ctx, remove)
+	if err != nil {
+		return nil, err
+	}
+	ctxRemoveUnreferenced, err := db.PreparexContext(ctx, removeUnreferenced)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectAll, err := db.PreparexContext(ctx, selectAll)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectRef, err := db.PreparexContext(ctx, selectRef)
+	if err != nil {
+		return nil, err
+	}
+	return &blobRepository{
+		db:                 db,
+		persist:            ctxPersist,
+		acquire:            ctxAcquire,
+		getByPath:          ctxGetByPath,
+		updateRef:          ctxUpdateRef,
+		decrementRef:       ctxDecrementRef,
+		remove:             ctxRemove,
+		removeUnreferenced: ctxRemoveUnreferenced,
+		selectAll:          ctxSelectAll,
+		selectRef:          ctxSelectRef,
+	}, err
+}
+
+func (s *blobRepository) Close() error {
+	var errorOccured error
+	if err := s.persist.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.acquire.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getByPath.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.updateRef.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.decrementRef.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.remove.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.removeUnreferenced.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectAll.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectRef.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+func (s *blobRepository) Persist(ctx context.Context, blob *model.Blob) error {
+	_, err := s.persist.ExecContext(ctx, blob.Hash, blob.Path, blob.Type, blob.Size, blob.RefCount)
+	return err
+}
+
+// Acquire adds a reference to the blob, storing it with one reference if it
+// doesn't exist yet. It reports whether the blob was created.
+func (s *blobRepository) Acquire(ctx context.Context, blob *model.Blob) (bool, error) {
+	res, err := s.acquire.ExecContext(ctx, blob.Hash, blob.Path, blob.Type, blob.Size, 1)
+	if err != nil {
+		return false, err
+	}
+	// MySQL reports one affected row for inserts and two for updates
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// Release removes a reference to the blob. Once the last reference is gone
+// the blob is removed, removeFile is called before the removal is committed
+// and the blob is kept if it fails. Meanwhile the blob stays locked, so it
+// can't be acquired while its file is removed.
+func (s *blobRepository) Release(ctx context.Context, fType int, path string, removeFile func() error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = func() error {
+		if _, err := tx.StmtxContext(ctx, s.decrementRef).ExecContext(ctx, fType, path); err != nil {
+			return err
+		}
+		res, err := tx.StmtxContext(ctx, s.removeUnreferenced).ExecContext(ctx, fType, path)
+		if err != nil {
+			return err
+		}
+		if removed, err := res.RowsAffected(); err != nil || removed == 0 {
+			return err
+		}
+		return removeFile()
+	}()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *blobRepository) GetByPath(ctx context.Context, fType int, path string) (*model.Blob, error) {
+	blob := &model.Blob{}
+	err := s.getByPath.GetContext(ctx, blob, fType, path)
+	return blob, err
+}
+
+// UpdateRefCount sets the reference count of the blob, if it is still
+// previous. Counts which changed meanwhile are left alone.
+func (s *blobRepository) UpdateRefCount(ctx context.Context, blob *model.Blob, previous int) error {
+	_, err := s.updateRef.ExecContext(ctx, blob.RefCount, blob.Type, blob.Path, previous)
+	return err
+}
+
+// Remove removes the blob, if its reference count is still previous
+func (s *blobRepository) Remove(ctx context.Context, blob *model.Blob, previous int) error {
+	_, err := s.remove.ExecContext(ctx, blob.Type, blob.Path, previous)
+	return err
+}
+
+func (s *blobRepository) SelectAll(ctx context.Context) ([]*model.Blob, error) {
+	blobs := []*model.Blob{}
+	err := s.selectAll.SelectContext(ctx, &blobs)
+	return blobs, err
+}
+
+// SelectReferences returns every file referenced by posts, the reference
+// count of the returned blobs is the number of referencing posts
+func (s *blobRepository) SelectReferences(ctx context.Context) ([]*model.Blob, error) {
+	blobs := []*model.Blob{}
+	err := s.selectRef.SelectContext(ctx, &blobs)
+	return blobs, err
+}
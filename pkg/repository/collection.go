@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type collectionRepository struct {
+	persist      *sqlx.Stmt
+	getByUid     *sqlx.Stmt
+	getDefault   *sqlx.Stmt
+	selectByUser *sqlx.Stmt
+	remove       *sqlx.Stmt
+	addPost      *sqlx.Stmt
+	removePost   *sqlx.Stmt
+}
+
+func NewCollectionRepository(db *sqlx.DB) (*collectionRepository, error) {
+	ctx := context.Background()
+	persist, _ := sqlz.Newx(db).InsertInto("collections").Columns("unique_id", "user_id",
+		"name", "is_default").Values("?", "?", "?", "?").ToSQL(false)
+
+	getByUid, _ := sqlz.Newx(db).Select("*").From("collections").
+		Where(sqlz.Eq("unique_id", "?")).ToSQL(false)
+
+	getDefault, _ := sqlz.Newx(db).Select("*").From("collections").
+		Where(sqlz.Eq("user_id", "?"), sqlz.Eq("is_default", "?")).ToSQL(false)
+
+	selectByUser, _ := sqlz.Newx(db).Select("*").From("collections").
+		Where(sqlz.Eq("user_id", "?")).
+		OrderBy(sqlz.Desc("is_default"), sqlz.Asc("created_at")).ToSQL(false)
+
+	remove, _ := sqlz.Newx(db).DeleteFrom("collections").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+
+	addPost, _ := sqlz.Newx(db).InsertInto("collection_posts").Columns("collection_id", "post_id").
+		Values("?", "?").ToSQL(false)
+	addPost += " ON DUPLICATE KEY UPDATE post_id = post_id"
+
+	removePost, _ := sqlz.Newx(db).DeleteFrom("collection_posts").
+		Where(sqlz.Eq("collection_id", "?"), sqlz.Eq("post_id", "?")).ToSQL(false)
+
+	ctxPersist, err := db.PreparexContext(ctx, persist)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetByUid, err := db.PreparexContext(ctx, getByUid)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetDefault, err := db.PreparexContext(ctx, getDefault)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectByUser, err := db.PreparexContext(ctx, selectByUser)
+	if err != nil {
+		return nil, err
+	}
+	ctxRemove, err := db.PreparexContext(ctx, remove)
+	if err != nil {
+		return nil, err
+	}
+	ctxAddPost, err := db.PreparexContext(ctx, addPost)
+	if err != nil {
+		return nil, err
+	}
+	ctxRemovePost, err := db.PreparexContext(ctx, removePost)
+	if err != nil {
+		return nil, err
+	}
+	return &collectionRepository{
+		persist:      ctxPersist,
+		getByUid:     ctxGetByUid,
+		getDefault:   ctxGetDefault,
+		selectByUser: ctxSelectByUser,
+		remove:       ctxRemove,
+		addPost:      ctxAddPost,
+		removePost:   ctxRemovePost,
+	}, err
+}
+
+func (s *collectionRepository) Close() error {
+	var errorOccured error
+	if err := s.persist.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getByUid.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getDefault.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectByUser.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.remove.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.addPost.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.removePost.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+func (s *collectionRepository) Persist(ctx context.Context, collection *model.Collection) error {
+	_, err := s.persist.ExecContext(ctx, collection.UniqueID, collection.UserID,
+		collection.Name, collection.Default)
+	return err
+}
+
+func (s *collectionRepository) GetByUid(ctx context.Context, uid string) (*model.Collection, error) {
+	collection := &model.Collection{}
+	err := s.getByUid.GetContext(ctx, collection, uid)
+	return collection, err
+}
+
+func (s *collectionRepository) UniqueIdExists(ctx context.Context, uid string) (bool, error) {
+	if _, err := s.GetByUid(ctx, uid); err != nil && err != sql.ErrNoRows {
+		return true, err
+	}
+	return false, nil
+}
+
+// GetDefault returns the default collection of the user, sql.ErrNoRows is
+// returned if the user has not saved any post yet
+func (s *collectionRepository) GetDefault(ctx context.Context, user *model.User) (*model.Collection, error) {
+	collection := &model.Collection{}
+	err := s.getDefault.GetContext(ctx, collection, user.ID, true)
+	return collection, err
+}
+
+// SelectByUser returns the collections of the user, the default collection first
+func (s *collectionRepository) SelectByUser(ctx context.Context, user *model.User) ([]*model.Collection, error) {
+	collections := []*model.Collection{}
+	err := s.selectByUser.SelectContext(ctx, &collections, user.ID)
+	return collections, err
+}
+
+// Remove deletes the collection, the collected posts are kept
+func (s *collectionRepository) Remove(ctx context.Context, collection *model.Collection) error {
+	_, err := s.remove.ExecContext(ctx, collection.ID)
+	return err
+}
+
+// AddPost adds the post to the collection, adding it twice has no effect
+func (s *collectionRepository) AddPost(ctx context.Context, collection *model.Collection, post *model.Post) error {
+	_, err := s.addPost.ExecContext(ctx, collection.ID, post.ID)
+	return err
+}
+
+func (s *collectionRepository) RemovePost(ctx context.Context, collection *model.Collection, post *model.Post) error {
+	_, err := s.removePost.ExecContext(ctx, collection.ID, post.ID)
+	return err
+}
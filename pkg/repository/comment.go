@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type commentRepository struct {
+	db           *sqlx.DB
+	persist      *sqlx.Stmt
+	getByUid     *sqlx.Stmt
+	selectByPost *sqlx.Stmt
+	update       *sqlx.Stmt
+	remove       *sqlx.Stmt
+}
+
+func NewCommentRepository(db *sqlx.DB) (*commentRepository, error) {
+	ctx := context.Background()
+	persist, _ := sqlz.Newx(db).InsertInto("comments").Columns("unique_id", "post_id",
+		"user_id", "parent_id", "content").Values("?", "?", "?", "?", "?").ToSQL(false)
+
+	getByUid, _ := sqlz.Newx(db).Select("c.*", "u.name AS author").From("comments c").
+		InnerJoin("users u", sqlz.Eq("u.id", sqlz.Indirect("c.user_id"))).
+		Where(sqlz.Eq("c.unique_id", "?")).ToSQL(false)
+
+	selectByPost, _ := sqlz.Newx(db).Select("c.*", "u.name AS author").From("comments c").
+		InnerJoin("users u", sqlz.Eq("u.id", sqlz.Indirect("c.user_id"))).
+		Where(sqlz.Eq("c.post_id", "?")).OrderBy(sqlz.Asc("c.created_at"), sqlz.Asc("c.id")).ToSQL(false)
+
+	update, _ := sqlz.Newx(db).Update("comments").Set("content", "?").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+
+	remove, _ := sqlz.Newx(db).DeleteFrom("comments").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+
+	ctxPersist, err := db.PreparexContext(ctx, persist)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetByUid, err := db.PreparexContext(ctx, getByUid)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectByPost, err := db.PreparexContext(ctx, selectByPost)
+	if err != nil {
+		return nil, err
+	}
+	ctxUpdate, err := db.PreparexContext(ctx, update)
+	if err != nil {
+		return nil, err
+	}
+	ctxRemove, err := db.PreparexContext(ctx, remove)
+	if err != nil {
+		return nil, err
+	}
+	return &commentRepository{
+		db:           db,
+		persist:      ctxPersist,
+		getByUid:     ctxGetByUid,
+		selectByPost: ctxSelectByPost,
+		update:       ctxUpdate,
+		remove:       ctxRemove,
+	}, err
+}
+
+func (s *commentRepository) Close() error {
+	var errorOccured error
+	if err := s.persist.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getByUid.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectByPost.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.update.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.remove.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+func (s *commentRepository) Persist(ctx context.Context, comment *model.Comment) error {
+	res, err := s.persist.ExecContext(ctx, comment.UniqueID, comment.PostID, comment.UserID,
+		comment.ParentID, comment.Content)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	comment.ID = int(id)
+	return err
+}
+
+func (s *commentRepository) GetByUid(ctx context.Context, uid string) (*model.Comment, error) {
+	comment := &model.Comment{}
+	err := s.getByUid.GetContext(ctx, comment, uid)
+	return comment, err
+}
+
+func (s *commentRepository) UniqueIdExists(ctx context.Context, uid string) (bool, error) {
+	if _, err := s.GetByUid(ctx, uid); err != nil && err != sql.ErrNoRows {
+		return true, err
+	}
+	return false, nil
+}
+
+// SelectByPost returns all comments of the post, oldest first
+func (s *commentRepository) SelectByPost(ctx context.Context, post *model.Post) ([]*model.Comment, error) {
+	comments := []*model.Comment{}
+	err := s.selectByPost.SelectContext(ctx, &comments, post.ID)
+	return comments, err
+}
+
+func (s *commentRepository) Update(ctx context.Context, comment *model.Comment) error {
+	_, err := s.update.ExecContext(ctx, comment.Content, comment.ID)
+	return err
+}
+
+// Remove deletes the comment together with all replies
+func (s *commentRepository) Remove(ctx context.Context, comment *model.Comment) error {
+	_, err := s.remove.ExecContext(ctx, comment.ID)
+	return err
+}
+
+// SelectFeed returns the comments on posts of the groups following the
+// cursor, the latest first
+func (s *commentRepository) SelectFeed(ctx context.Context, groupIDs []int, before *model.FeedCursor,
+	limit uint64) ([]*model.FeedComment, error) {
+	comments := []*model.FeedComment{}
+	if len(groupIDs) == 0 {
+		return comments, nil
+	}
+	err := sqlz.Newx(s.db).Select("c.*", "u.name AS author", "p.unique_id AS post_uid",
+		"g.unique_id AS group_uid").From("comments c").
+		InnerJoin("users u", sqlz.Eq("u.id", sqlz.Indirect("c.user_id"))).
+		InnerJoin("posts p", sqlz.Eq("p.id", sqlz.Indirect("c.post_id"))).
+		InnerJoin("groups g", sqlz.Eq("g.id", sqlz.Indirect("p.group_id"))).
+		Where(feedConditions("p.group_id", "c.id", "c.created_at", groupIDs, before)...).
+		OrderBy(sqlz.Desc("c.created_at"), sqlz.Desc("c.id")).Limit(int64(limit)).
+		GetAllContext(ctx, &comments)
+	return comments, err
+}
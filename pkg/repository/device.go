@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type deviceRepository struct {
+	persist      *sqlx.Stmt
+	getByToken   *sqlx.Stmt
+	selectByUser *sqlx.Stmt
+	remove       *sqlx.Stmt
+}
+
+func NewDeviceRepository(db *sqlx.DB) (*deviceRepository, error) {
+	ctx := context.Background()
+	persist, _ := sqlz.Newx(db).InsertInto("devices").Columns("user_id", "platform", "token").
+		Values("?", "?", "?").ToSQL(false)
+	persist += " ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), platform = VALUES(platform)"
+
+	getByToken, _ := sqlz.Newx(db).Select("*").From("devices").
+		Where(sqlz.Eq("token", "?")).ToSQL(false)
+
+	selectByUser, _ := sqlz.Newx(db).Select("*").From("devices").
+		Where(sqlz.Eq("user_id", "?")).ToSQL(false)
+
+	remove, _ := sqlz.Newx(db).DeleteFrom("devices").
+		Where(sqlz.Eq("token", "?")).ToSQL(false)
+
+	ctxPersist, err := db.PreparexContext(ctx, persist)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetByToken, err := db.PreparexContext(ctx, getByToken)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectByUser, err := db.PreparexContext(ctx, selectByUser)
+	if err != nil {
+		return nil, err
+	}
+	ctxRemove, err := db.PreparexContext(ctx, remove)
+	if err != nil {
+		return nil, err
+	}
+	return &deviceRepository{
+		persist:      ctxPersist,
+		getByToken:   ctxGetByToken,
+		selectByUser: ctxSelectByUser,
+		remove:       ctxRemove,
+	}, err
+}
+
+func (s *deviceRepository) Close() error {
+	var errorOccured error
+	if err := s.persist.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getByToken.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectByUser.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.remove.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+// Persist registers the device, registering a known token again moves it
+// to the user
+func (s *deviceRepository) Persist(ctx context.Context, device *model.Device) error {
+	_, err := s.persist.ExecContext(ctx, device.UserID, device.Platform, device.Token)
+	return err
+}
+
+func (s *deviceRepository) GetByToken(ctx context.Context, token string) (*model.Device, error) {
+	device := &model.Device{}
+	err := s.getByToken.GetContext(ctx, device, token)
+	return device, err
+}
+
+func (s *deviceRepository) SelectByUser(ctx context.Context, userID int) ([]*model.Device, error) {
+	devices := []*model.Device{}
+	err := s.selectByUser.SelectContext(ctx, &devices, userID)
+	return devices, err
+}
+
+func (s *deviceRepository) Remove(ctx context.Context, device *model.Device) error {
+	_, err := s.remove.ExecContext(ctx, device.Token)
+	return err
+}
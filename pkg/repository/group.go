@@ -11,6 +11,7 @@ import (
 )
 
 type groupRepository struct {
+	db                   *sqlx.DB
 	persistGroup         *sqlx.Stmt
 	getByUid             *sqlx.Stmt
 	stmtAddUserToGroup   *sqlx.Stmt
@@ -19,6 +20,7 @@ type groupRepository struct {
 	stmtUpdateVisibility *sqlx.Stmt
 	stmtSelectByUser     *sqlx.Stmt
 	stmtRemove           *sqlx.Stmt
+	getByID              *sqlx.Stmt
 }
 
 func NewGroupRepository(db *sqlx.DB) (*groupRepository, error) {
@@ -48,6 +50,9 @@ func NewGroupRepository(db *sqlx.DB) (*groupRepository, error) {
 
 	remove, _ := sqlz.Newx(db).DeleteFrom("groups").Where(sqlz.Eq("id", "?")).ToSQL(false)
 
+	getByID, _ := sqlz.Newx(db).Select("*").From("groups").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+
 	ctxPersist, err := db.PreparexContext(ctx, persist)
 	if err != nil {
 		return nil, err
@@ -80,7 +85,12 @@ func NewGroupRepository(db *sqlx.DB) (*groupRepository, error) {
 	if err != nil {
 		return nil, err
 	}
+	ctxGetByID, err := db.PreparexContext(ctx, getByID)
+	if err != nil {
+		return nil, err
+	}
 	return &groupRepository{
+		db:                   db,
 		persistGroup:         ctxPersist,
 		getByUid:             ctxGetByUid,
 		stmtAddUserToGroup:   ctxAddUserToGroup,
@@ -89,6 +99,7 @@ func NewGroupRepository(db *sqlx.DB) (*groupRepository, error) {
 		stmtUpdateVisibility: ctxUpdateVisibility,
 		stmtSelectByUser:     ctxSelectByUser,
 		stmtRemove:           ctxRemoveGroup,
+		getByID:              ctxGetByID,
 	}, err
 }
 
@@ -118,6 +129,9 @@ func (s *groupRepository) Close() error {
 	if err := s.stmtRemove.Close(); err != nil {
 		errorOccured = err
 	}
+	if err := s.getByID.Close(); err != nil {
+		errorOccured = err
+	}
 	return errorOccured
 }
 
@@ -185,3 +199,26 @@ func (s *groupRepository) RemoveGroup(ctx context.Context, group *model.Group) e
 	_, err := s.stmtRemove.ExecContext(ctx, group.ID)
 	return err
 }
+
+func (s *groupRepository) GetByID(ctx context.Context, id int) (*model.Group, error) {
+	group := &model.Group{}
+	err := s.getByID.GetContext(ctx, group, id)
+	return group, err
+}
+
+// SelectMemberships returns the users who joined the groups following the
+// cursor, the latest first
+func (s *groupRepository) SelectMemberships(ctx context.Context, groupIDs []int, before *model.FeedCursor,
+	limit uint64) ([]*model.Membership, error) {
+	memberships := []*model.Membership{}
+	if len(groupIDs) == 0 {
+		return memberships, nil
+	}
+	err := sqlz.Newx(s.db).Select("gu.*", "u.name", "g.unique_id AS group_uid").From("group_user gu").
+		InnerJoin("users u", sqlz.Eq("u.id", sqlz.Indirect("gu.user_id"))).
+		InnerJoin("groups g", sqlz.Eq("g.id", sqlz.Indirect("gu.group_id"))).
+		Where(feedConditions("gu.group_id", "gu.id", "gu.created_at", groupIDs, before)...).
+		OrderBy(sqlz.Desc("gu.created_at"), sqlz.Desc("gu.id")).Limit(int64(limit)).
+		GetAllContext(ctx, &memberships)
+	return memberships, err
+}
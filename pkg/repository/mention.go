@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type mentionRepository struct {
+	persist      *sqlx.Stmt
+	selectByUser *sqlx.Stmt
+}
+
+func NewMentionRepository(db *sqlx.DB) (*mentionRepository, error) {
+	ctx := context.Background()
+	persist, _ := sqlz.Newx(db).InsertInto("mentions").Columns("user_id", "author_id",
+		"post_id", "comment_id").Values("?", "?", "?", "?").ToSQL(false)
+
+	selectByUser, _ := sqlz.Newx(db).Select("m.*", "u.name AS author", "p.unique_id AS post_uid",
+		"p.group_id", `COALESCE(c.unique_id, "") AS comment_uid`).From("mentions m").
+		InnerJoin("users u", sqlz.Eq("u.id", sqlz.Indirect("m.author_id"))).
+		InnerJoin("posts p", sqlz.Eq("p.id", sqlz.Indirect("m.post_id"))).
+		LeftJoin("comments c", sqlz.Eq("c.id", sqlz.Indirect("m.comment_id"))).
+		Where(sqlz.Eq("m.user_id", "?")).OrderBy(sqlz.Desc("m.created_at"), sqlz.Desc("m.id")).ToSQL(false)
+
+	ctxPersist, err := db.PreparexContext(ctx, persist)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectByUser, err := db.PreparexContext(ctx, selectByUser)
+	if err != nil {
+		return nil, err
+	}
+	return &mentionRepository{
+		persist:      ctxPersist,
+		selectByUser: ctxSelectByUser,
+	}, err
+}
+
+func (s *mentionRepository) Close() error {
+	var errorOccured error
+	if err := s.persist.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectByUser.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+func (s *mentionRepository) Persist(ctx context.Context, mention *model.Mention) error {
+	res, err := s.persist.ExecContext(ctx, mention.UserID, mention.AuthorID,
+		mention.PostID, mention.CommentID)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	mention.ID = int(id)
+	return err
+}
+
+// SelectByUser returns the mentions of the user, the latest first
+func (s *mentionRepository) SelectByUser(ctx context.Context, user *model.User) ([]*model.Mention, error) {
+	mentions := []*model.Mention{}
+	err := s.selectByUser.SelectContext(ctx, &mentions, user.ID)
+	return mentions, err
+}
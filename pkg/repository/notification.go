@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type notificationRepository struct {
+	persist            *sqlx.Stmt
+	getByUid           *sqlx.Stmt
+	selectByUser       *sqlx.Stmt
+	selectUnreadByUser *sqlx.Stmt
+	markRead           *sqlx.Stmt
+	markAllRead        *sqlx.Stmt
+	getPreference      *sqlx.Stmt
+	selectPreferences  *sqlx.Stmt
+	setPreference      *sqlx.Stmt
+}
+
+func NewNotificationRepository(db *sqlx.DB) (*notificationRepository, error) {
+	ctx := context.Background()
+	limit := " LIMIT ?"
+	persist, _ := sqlz.Newx(db).InsertInto("notifications").Columns("unique_id", "user_id",
+		"type", "actor_id", "post_uid", "group_uid").Values("?", "?", "?", "?", "?", "?").ToSQL(false)
+
+	getByUid, _ := sqlz.Newx(db).Select("n.*", "u.name AS actor").From("notifications n").
+		InnerJoin("users u", sqlz.Eq("u.id", sqlz.Indirect("n.actor_id"))).
+		Where(sqlz.Eq("n.unique_id", "?")).ToSQL(false)
+
+	selectByUser, _ := sqlz.Newx(db).Select("n.*", "u.name AS actor").From("notifications n").
+		InnerJoin("users u", sqlz.Eq("u.id", sqlz.Indirect("n.actor_id"))).
+		Where(sqlz.Eq("n.user_id", "?")).
+		OrderBy(sqlz.Desc("n.created_at"), sqlz.Desc("n.id")).ToSQL(false)
+
+	selectUnreadByUser, _ := sqlz.Newx(db).Select("n.*", "u.name AS actor").From("notifications n").
+		InnerJoin("users u", sqlz.Eq("u.id", sqlz.Indirect("n.actor_id"))).
+		Where(sqlz.Eq("n.user_id", "?"), sqlz.Eq("n.is_read", "?")).
+		OrderBy(sqlz.Desc("n.created_at"), sqlz.Desc("n.id")).ToSQL(false)
+
+	markRead, _ := sqlz.Newx(db).Update("notifications").Set("is_read", "?").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+
+	markAllRead, _ := sqlz.Newx(db).Update("notifications").Set("is_read", "?").
+		Where(sqlz.Eq("user_id", "?")).ToSQL(false)
+
+	getPreference, _ := sqlz.Newx(db).Select("*").From("notification_preferences").
+		Where(sqlz.Eq("user_id", "?"), sqlz.Eq("type", "?")).ToSQL(false)
+
+	selectPreferences, _ := sqlz.Newx(db).Select("*").From("notification_preferences").
+		Where(sqlz.Eq("user_id", "?")).ToSQL(false)
+
+	setPreference, _ := sqlz.Newx(db).InsertInto("notification_preferences").
+		Columns("user_id", "type", "enabled").Values("?", "?", "?").ToSQL(false)
+	setPreference += " ON DUPLICATE KEY UPDATE enabled = VALUES(enabled)"
+
+	ctxPersist, err := db.PreparexContext(ctx, persist)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetByUid, err := db.PreparexContext(ctx, getByUid)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectByUser, err := db.PreparexContext(ctx, selectByUser+limit)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectUnreadByUser, err := db.PreparexContext(ctx, selectUnreadByUser+limit)
+	if err != nil {
+		return nil, err
+	}
+	ctxMarkRead, err := db.PreparexContext(ctx, markRead)
+	if err != nil {
+		return nil, err
+	}
+	ctxMarkAllRead, err := db.PreparexContext(ctx, markAllRead)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetPreference, err := db.PreparexContext(ctx, getPreference)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectPreferences, err := db.PreparexContext(ctx, selectPreferences)
+	if err != nil {
+		return nil, err
+	}
+	ctxSetPreference, err := db.PreparexContext(ctx, setPreference)
+	if err != nil {
+		return nil, err
+	}
+	return &notificationRepository{
+		persist:            ctxPersist,
+		getByUid:           ctxGetByUid,
+		selectByUser:       ctxSelectByUser,
+		selectUnreadByUser: ctxSelectUnreadByUser,
+		markRead:           ctxMarkRead,
+		markAllRead:        ctxMarkAllRead,
+		getPreference:      ctxGetPreference,
+		selectPreferences:  ctxSelectPreferences,
+		setPreference:      ctxSetPreference,
+	}, err
+}
+
+func (s *notificationRepository) Close() error {
+	var errorOccured error
+	if err := s.persist.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getByUid.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectByUser.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectUnreadByUser.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.markRead.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.markAllRead.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getPreference.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectPreferences.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.setPreference.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+func (s *notificationRepository) Persist(ctx context.Context, notification *model.Notification) error {
+	_, err := s.persist.ExecContext(ctx, notification.UniqueID, notification.UserID,
+		notification.Type, notification.ActorID, notification.PostUid, notification.GroupUid)
+	return err
+}
+
+func (s *notificationRepository) GetByUid(ctx context.Context, uid string) (*model.Notification, error) {
+	notification := &model.Notification{}
+	err := s.getByUid.GetContext(ctx, notification, uid)
+	return notification, err
+}
+
+// SelectByUser returns the latest notifications of the user, only the
+// unread ones if unread is set
+func (s *notificationRepository) SelectByUser(ctx context.Context, user *model.User,
+	unread bool, limit uint64) ([]*model.Notification, error) {
+	notifications := []*model.Notification{}
+	var err error
+	if unread {
+		err = s.selectUnreadByUser.SelectContext(ctx, &notifications, user.ID, false, limit)
+	} else {
+		err = s.selectByUser.SelectContext(ctx, &notifications, user.ID, limit)
+	}
+	return notifications, err
+}
+
+func (s *notificationRepository) MarkRead(ctx context.Context, notification *model.Notification) error {
+	_, err := s.markRead.ExecContext(ctx, true, notification.ID)
+	return err
+}
+
+func (s *notificationRepository) MarkAllRead(ctx context.Context, user *model.User) error {
+	_, err := s.markAllRead.ExecContext(ctx, true, user.ID)
+	return err
+}
+
+// IsEnabled reports whether the user wants to be notified about the type
+// of events, types without a stored preference are enabled
+func (s *notificationRepository) IsEnabled(ctx context.Context, userID int, kind string) (bool, error) {
+	preference := &model.NotificationPreference{}
+	err := s.getPreference.GetContext(ctx, preference, userID, kind)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	return preference.Enabled, err
+}
+
+// SelectPreferences returns the stored preferences of the user
+func (s *notificationRepository) SelectPreferences(ctx context.Context, user *model.User) ([]*model.NotificationPreference, error) {
+	preferences := []*model.NotificationPreference{}
+	err := s.selectPreferences.SelectContext(ctx, &preferences, user.ID)
+	return preferences, err
+}
+
+func (s *notificationRepository) SetPreference(ctx context.Context, preference *model.NotificationPreference) error {
+	_, err := s.setPreference.ExecContext(ctx, preference.UserID, preference.Type, preference.Enabled)
+	return err
+}
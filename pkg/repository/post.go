@@ -11,6 +11,7 @@ import (
 )
 
 type postRepository struct {
+	db                  *sqlx.DB
 	persist             *sqlx.Stmt
 	selectByUserID      *sqlx.Stmt
 	getByTitle          *sqlx.Stmt
@@ -19,10 +20,20 @@ type postRepository struct {
 	selectByParent      *sqlx.Stmt
 	selectLatest        *sqlx.Stmt
 	selectLatestOfGroup *sqlx.Stmt
+	selectByGroup       *sqlx.Stmt
 	addOptions          *sqlx.Stmt
 	removeOptions       *sqlx.Stmt
 	selectOptions       *sqlx.Stmt
 	removePost          *sqlx.Stmt
+	sumSizeByUser       *sqlx.Stmt
+	sumSizeByGroup      *sqlx.Stmt
+	addMedia            *sqlx.Stmt
+	selectMedia         *sqlx.Stmt
+	getByID             *sqlx.Stmt
+	upsertOption        *sqlx.Stmt
+	removeOption        *sqlx.Stmt
+	selectReactions     *sqlx.Stmt
+	selectByCollection  *sqlx.Stmt
 }
 
 func NewPostRepository(db *sqlx.DB) (*postRepository, error) {
@@ -39,6 +50,9 @@ func NewPostRepository(db *sqlx.DB) (*postRepository, error) {
 		Where(sqlz.IsNull("parent_id"), sqlz.Eq("group_id", "?")).
 		OrderBy(sqlz.Desc("created_at")).ToSQL(false)
 
+	selectByGroup, _ := sqlz.Newx(db).Select("*").From("detailed_posts").
+		Where(sqlz.Eq("group_id", "?")).ToSQL(false)
+
 	getByTitle, _ := sqlz.Newx(db).Select("*").From("detailed_posts").
 		Where(sqlz.IsNull("parent_id"), sqlz.IsNull("group_id")).
 		Where(sqlz.Like("title", "%?%")).
@@ -57,8 +71,8 @@ func NewPostRepository(db *sqlx.DB) (*postRepository, error) {
 		Where(sqlz.Eq("parent_id", "?")).OrderBy(sqlz.Desc("created_at")).ToSQL(false)
 
 	persist, _ := sqlz.Newx(db).InsertInto("posts").Columns("title", "user_id", "path",
-		"parent_id", "method", "type", "unique_id", "group_id").
-		Values("?", "?", "?", "?", "?", "?", "?", "?").ToSQL(false)
+		"parent_id", "method", "type", "unique_id", "group_id", "size", "content", "position",
+		"prompt").Values("?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?").ToSQL(false)
 
 	addOptions, _ := sqlz.Newx(db).InsertInto("options").Columns("post_uid",
 		"opt_key", "opt_value").Values("?", "?", "?").ToSQL(false)
@@ -72,6 +86,35 @@ func NewPostRepository(db *sqlx.DB) (*postRepository, error) {
 	removePost, _ := sqlz.Newx(db).DeleteFrom("posts").
 		Where(sqlz.Eq("unique_id", "?")).ToSQL(false)
 
+	sumSizeByUser, _ := sqlz.Newx(db).Select("COALESCE(SUM(size), 0)").From("posts").
+		Where(sqlz.Eq("user_id", "?")).ToSQL(false)
+
+	sumSizeByGroup, _ := sqlz.Newx(db).Select("COALESCE(SUM(size), 0)").From("posts").
+		Where(sqlz.Eq("group_id", "?")).ToSQL(false)
+
+	addMedia, _ := sqlz.Newx(db).InsertInto("post_media").Columns("post_id", "position",
+		"path", "type", "size").Values("?", "?", "?", "?", "?").ToSQL(false)
+
+	selectMedia, _ := sqlz.Newx(db).Select("*").From("post_media").
+		Where(sqlz.Eq("post_id", "?")).OrderBy(sqlz.Asc("position")).ToSQL(false)
+
+	getByID, _ := sqlz.Newx(db).Select("*").From("detailed_posts").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+
+	upsertOption, _ := sqlz.Newx(db).InsertInto("options").Columns("post_uid",
+		"opt_key", "opt_value").Values("?", "?", "?").ToSQL(false)
+	upsertOption += " ON DUPLICATE KEY UPDATE opt_value = VALUES(opt_value)"
+
+	removeOption, _ := sqlz.Newx(db).DeleteFrom("options").Where(
+		sqlz.Eq("post_uid", "?"), sqlz.Eq("opt_key", "?")).ToSQL(false)
+
+	selectReactions, _ := sqlz.Newx(db).Select("post_id", "kind", "COUNT(*) AS count").From("reactions").
+		Where(sqlz.Eq("post_id", "?")).GroupBy("post_id", "kind").ToSQL(false)
+
+	selectByCollection, _ := sqlz.Newx(db).Select("p.*").From("detailed_posts p").
+		InnerJoin("collection_posts c", sqlz.Eq("c.post_id", sqlz.Indirect("p.id"))).
+		Where(sqlz.Eq("c.collection_id", "?")).OrderBy(sqlz.Desc("c.created_at")).ToSQL(false)
+
 	ctxPersistPost, err := db.PreparexContext(ctx, persist)
 	if err != nil {
 		return nil, err
@@ -104,6 +147,10 @@ func NewPostRepository(db *sqlx.DB) (*postRepository, error) {
 	if err != nil {
 		return nil, err
 	}
+	ctxSelectByGroup, err := db.PreparexContext(ctx, selectByGroup)
+	if err != nil {
+		return nil, err
+	}
 	ctxAddOptions, err := db.PreparexContext(ctx, addOptions)
 	if err != nil {
 		return nil, err
@@ -120,7 +167,44 @@ func NewPostRepository(db *sqlx.DB) (*postRepository, error) {
 	if err != nil {
 		return nil, err
 	}
+	ctxSumSizeByUser, err := db.PreparexContext(ctx, sumSizeByUser)
+	if err != nil {
+		return nil, err
+	}
+	ctxSumSizeByGroup, err := db.PreparexContext(ctx, sumSizeByGroup)
+	if err != nil {
+		return nil, err
+	}
+	ctxAddMedia, err := db.PreparexContext(ctx, addMedia)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectMedia, err := db.PreparexContext(ctx, selectMedia)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetByID, err := db.PreparexContext(ctx, getByID)
+	if err != nil {
+		return nil, err
+	}
+	ctxUpsertOption, err := db.PreparexContext(ctx, upsertOption)
+	if err != nil {
+		return nil, err
+	}
+	ctxRemoveOption, err := db.PreparexContext(ctx, removeOption)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectReactions, err := db.PreparexContext(ctx, selectReactions)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectByCollection, err := db.PreparexContext(ctx, selectByCollection)
+	if err != nil {
+		return nil, err
+	}
 	return &postRepository{
+		db:                  db,
 		persist:             ctxPersistPost,
 		selectByUserID:      ctxSelectByUserID,
 		getByTitle:          ctxGetByTitle,
@@ -128,11 +212,21 @@ func NewPostRepository(db *sqlx.DB) (*postRepository, error) {
 		selectByParent:      ctxSelectByParent,
 		selectLatest:        ctxSelectLatest,
 		selectLatestOfGroup: ctxSelectLatestOfGroup,
+		selectByGroup:       ctxSelectByGroup,
 		addOptions:          ctxAddOptions,
 		removeOptions:       ctxRemoveOptions,
 		selectOptions:       ctxSelectOptions,
 		getByTitleInGroup:   ctxGetByTitleInGroup,
 		removePost:          ctxRemovePost,
+		sumSizeByUser:       ctxSumSizeByUser,
+		sumSizeByGroup:      ctxSumSizeByGroup,
+		addMedia:            ctxAddMedia,
+		selectMedia:         ctxSelectMedia,
+		getByID:             ctxGetByID,
+		upsertOption:        ctxUpsertOption,
+		removeOption:        ctxRemoveOption,
+		selectReactions:     ctxSelectReactions,
+		selectByCollection:  ctxSelectByCollection,
 	}, err
 }
 
@@ -162,6 +256,9 @@ func (s *postRepository) Close() error {
 	if err := s.selectLatestOfGroup.Close(); err != nil {
 		errorOccured = err
 	}
+	if err := s.selectByGroup.Close(); err != nil {
+		errorOccured = err
+	}
 	if err := s.addOptions.Close(); err != nil {
 		errorOccured = err
 	}
@@ -174,6 +271,33 @@ func (s *postRepository) Close() error {
 	if err := s.removePost.Close(); err != nil {
 		errorOccured = err
 	}
+	if err := s.sumSizeByUser.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.sumSizeByGroup.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.addMedia.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectMedia.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getByID.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.upsertOption.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.removeOption.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectReactions.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectByCollection.Close(); err != nil {
+		errorOccured = err
+	}
 	return errorOccured
 }
 
@@ -181,7 +305,7 @@ func (s *postRepository) SelectByUser(ctx context.Context, user *model.User) ([]
 	posts := []*model.Post{}
 	err := s.selectByUserID.SelectContext(ctx, &posts, user.ID)
 	if err == nil {
-		err = s.appendOptionsMult(ctx, posts)
+		err = s.appendDetailsMult(ctx, posts)
 	}
 	return posts, err
 }
@@ -190,7 +314,7 @@ func (s *postRepository) GetByTitle(ctx context.Context, title string, limit int
 	posts := []*model.Post{}
 	err := s.getByTitle.SelectContext(ctx, &posts, "%"+title+"%", limit)
 	if err == nil {
-		err = s.appendOptionsMult(ctx, posts)
+		err = s.appendDetailsMult(ctx, posts)
 	}
 	return posts, err
 }
@@ -200,14 +324,19 @@ func (s *postRepository) GetByTitleInGroup(ctx context.Context, title string,
 	posts := []*model.Post{}
 	err := s.getByTitleInGroup.SelectContext(ctx, &posts, "%"+title+"%", group.ID, limit)
 	if err == nil {
-		err = s.appendOptionsMult(ctx, posts)
+		err = s.appendDetailsMult(ctx, posts)
 	}
 	return posts, err
 }
 
 func (c *postRepository) Persist(ctx context.Context, post *model.Post) error {
-	_, err := c.persist.ExecContext(ctx, post.Title, post.UserID, post.Path,
-		post.ParentID, post.Method, post.Type, post.UniqueID, post.GroupID)
+	res, err := c.persist.ExecContext(ctx, post.Title, post.UserID, post.Path,
+		post.ParentID, post.Method, post.Type, post.UniqueID, post.GroupID, post.Size, post.Content, post.Position, post.Prompt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	post.ID = int(id)
 	return err
 }
 
@@ -215,7 +344,7 @@ func (s *postRepository) GetByUid(ctx context.Context, uid string) (*model.Post,
 	post := &model.Post{}
 	err := s.getByUID.GetContext(ctx, post, uid)
 	if err == nil {
-		err = s.appendOptions(ctx, post)
+		err = s.appendDetails(ctx, post)
 	}
 	return post, err
 }
@@ -224,7 +353,7 @@ func (s *postRepository) SelectByParent(ctx context.Context, parent *model.Post)
 	posts := []*model.Post{}
 	err := s.selectByParent.SelectContext(ctx, &posts, parent.ID)
 	if err == nil {
-		err = s.appendOptionsMult(ctx, posts)
+		err = s.appendDetailsMult(ctx, posts)
 	}
 	return posts, err
 }
@@ -240,7 +369,7 @@ func (s *postRepository) SelectLatest(ctx context.Context, limit uint64) ([]*mod
 	posts := []*model.Post{}
 	err := s.selectLatest.SelectContext(ctx, &posts, limit)
 	if err == nil {
-		err = s.appendOptionsMult(ctx, posts)
+		err = s.appendDetailsMult(ctx, posts)
 	}
 	return posts, err
 }
@@ -249,48 +378,151 @@ func (s *postRepository) SelectLatestOfGroup(ctx context.Context, group *model.G
 	posts := []*model.Post{}
 	err := s.selectLatestOfGroup.SelectContext(ctx, &posts, group.ID, limit)
 	if err == nil {
-		err = s.appendOptionsMult(ctx, posts)
+		err = s.appendDetailsMult(ctx, posts)
 	}
 	return posts, err
 }
 
-func (s *postRepository) appendOptions(ctx context.Context, post *model.Post) error {
+func (s *postRepository) SelectByGroup(ctx context.Context, group *model.Group) ([]*model.Post, error) {
+	posts := []*model.Post{}
+	err := s.selectByGroup.SelectContext(ctx, &posts, group.ID)
+	if err == nil {
+		err = s.appendDetailsMult(ctx, posts)
+	}
+	return posts, err
+}
+
+func (s *postRepository) appendDetails(ctx context.Context, post *model.Post) error {
 	options, err := s.SelectOptions(ctx, post)
+	if err != nil {
+		return err
+	}
 	post.Options = append(post.Options, options...)
-	return err
+	media, err := s.SelectMedia(ctx, post)
+	if err != nil {
+		return err
+	}
+	post.Media = append(post.Media, media...)
+	counts := []*model.ReactionCount{}
+	if err := s.selectReactions.SelectContext(ctx, &counts, post.ID); err != nil {
+		return err
+	}
+	appendReactions([]*model.Post{post}, counts)
+	return nil
 }
 
-func (s *postRepository) appendOptionsMult(ctx context.Context, posts []*model.Post) error {
+// appendReactions adds the reaction counts to their posts
+func appendReactions(posts []*model.Post, counts []*model.ReactionCount) {
+	byID := make(map[int]*model.Post, len(posts))
 	for _, post := range posts {
-		err := s.appendOptions(ctx, post)
-		if err != nil {
-			return err
+		post.Reactions = map[string]int{}
+		byID[post.ID] = post
+	}
+	for _, count := range counts {
+		if post, ok := byID[count.PostID]; ok {
+			post.Reactions[count.Kind] = count.Count
 		}
 	}
-	return nil
 }
 
-func (s *postRepository) AddOptions(ctx context.Context, post *model.Post, options []*model.Option) error {
-	for _, v := range options {
-		_, err := s.addOptions.ExecContext(ctx, post.UniqueID, v.Key, v.Value)
-		if err != nil {
-			return err
-		}
+// appendDetailsMult loads the options, media and reactions of all posts
+// with one query each, instead of several queries per post
+func (s *postRepository) appendDetailsMult(ctx context.Context, posts []*model.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	uids := make([]interface{}, len(posts))
+	ids := make([]interface{}, len(posts))
+	byUid := make(map[string]*model.Post, len(posts))
+	byID := make(map[int]*model.Post, len(posts))
+	for i, post := range posts {
+		uids[i], ids[i] = post.UniqueID, post.ID
+		byUid[post.UniqueID], byID[post.ID] = post, post
+	}
+	options := []*model.Option{}
+	err := sqlz.Newx(s.db).Select("*").From("options").
+		Where(sqlz.In("post_uid", uids...)).GetAllContext(ctx, &options)
+	if err != nil {
+		return err
+	}
+	for _, option := range options {
+		post := byUid[option.PostUid]
+		post.Options = append(post.Options, option)
 	}
+	media := []*model.PostMedia{}
+	err = sqlz.Newx(s.db).Select("*").From("post_media").
+		Where(sqlz.In("post_id", ids...)).
+		OrderBy(sqlz.Asc("post_id"), sqlz.Asc("position")).GetAllContext(ctx, &media)
+	if err != nil {
+		return err
+	}
+	for _, m := range media {
+		post := byID[m.PostID]
+		post.Media = append(post.Media, m)
+	}
+	counts := []*model.ReactionCount{}
+	err = sqlz.Newx(s.db).Select("post_id", "kind", "COUNT(*) AS count").From("reactions").
+		Where(sqlz.In("post_id", ids...)).GroupBy("post_id", "kind").GetAllContext(ctx, &counts)
+	if err != nil {
+		return err
+	}
+	appendReactions(posts, counts)
 	return nil
 }
 
+func (s *postRepository) AddOptions(ctx context.Context, post *model.Post, options []*model.Option) error {
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		return execOptions(ctx, tx.StmtxContext(ctx, s.addOptions), post, options)
+	})
+}
+
+// UpsertOptions adds the options to the post, options with an existing key
+// are updated
+func (s *postRepository) UpsertOptions(ctx context.Context, post *model.Post, options []*model.Option) error {
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		return execOptions(ctx, tx.StmtxContext(ctx, s.upsertOption), post, options)
+	})
+}
+
+func (s *postRepository) RemoveOption(ctx context.Context, post *model.Post, key string) error {
+	_, err := s.removeOption.ExecContext(ctx, post.UniqueID, key)
+	return err
+}
+
 func (s *postRepository) RemoveOptions(ctx context.Context, post *model.Post) error {
 	_, err := s.removeOptions.ExecContext(ctx, post.UniqueID)
 	return err
 }
 
 func (s *postRepository) SetOptions(ctx context.Context, post *model.Post, options []*model.Option) error {
-	err := s.RemoveOptions(ctx, post)
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.StmtxContext(ctx, s.removeOptions).ExecContext(ctx, post.UniqueID); err != nil {
+			return err
+		}
+		return execOptions(ctx, tx.StmtxContext(ctx, s.addOptions), post, options)
+	})
+}
+
+func execOptions(ctx context.Context, stmt *sqlx.Stmt, post *model.Post, options []*model.Option) error {
+	for _, v := range options {
+		if _, err := stmt.ExecContext(ctx, post.UniqueID, v.Key, v.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inTx runs f within a transaction, which is rolled back if f fails
+func (s *postRepository) inTx(ctx context.Context, f func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return s.AddOptions(ctx, post, options)
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *postRepository) SelectOptions(ctx context.Context, post *model.Post) ([]*model.Option, error) {
@@ -303,3 +535,68 @@ func (s *postRepository) RemovePost(ctx context.Context, post *model.Post) error
 	_, err := s.removePost.ExecContext(ctx, post.UniqueID)
 	return err
 }
+
+func (s *postRepository) SumSizeByUser(ctx context.Context, user *model.User) (int64, error) {
+	var size int64
+	err := s.sumSizeByUser.GetContext(ctx, &size, user.ID)
+	return size, err
+}
+
+func (s *postRepository) SumSizeByGroup(ctx context.Context, group *model.Group) (int64, error) {
+	var size int64
+	err := s.sumSizeByGroup.GetContext(ctx, &size, group.ID)
+	return size, err
+}
+
+func (s *postRepository) AddMedia(ctx context.Context, post *model.Post, media []*model.PostMedia) error {
+	for _, m := range media {
+		_, err := s.addMedia.ExecContext(ctx, post.ID, m.Position, m.Path, m.Type, m.Size)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *postRepository) SelectMedia(ctx context.Context, post *model.Post) ([]*model.PostMedia, error) {
+	media := []*model.PostMedia{}
+	err := s.selectMedia.SelectContext(ctx, &media, post.ID)
+	return media, err
+}
+
+func (s *postRepository) GetByID(ctx context.Context, id int) (*model.Post, error) {
+	post := &model.Post{}
+	err := s.getByID.GetContext(ctx, post, id)
+	if err == nil {
+		err = s.appendDetails(ctx, post)
+	}
+	return post, err
+}
+
+// SelectByCollection returns the posts of the collection, the latest added first
+func (s *postRepository) SelectByCollection(ctx context.Context, collection *model.Collection) ([]*model.Post, error) {
+	posts := []*model.Post{}
+	err := s.selectByCollection.SelectContext(ctx, &posts, collection.ID)
+	if err == nil {
+		err = s.appendDetailsMult(ctx, posts)
+	}
+	return posts, err
+}
+
+// SelectFeed returns the posts of the groups following the cursor, the
+// latest first
+func (s *postRepository) SelectFeed(ctx context.Context, groupIDs []int, before *model.FeedCursor,
+	limit uint64) ([]*model.Post, error) {
+	posts := []*model.Post{}
+	if len(groupIDs) == 0 {
+		return posts, nil
+	}
+	err := sqlz.Newx(s.db).Select("*").From("detailed_posts").
+		Where(feedConditions("group_id", "id", "created_at", groupIDs, before)...).
+		OrderBy(sqlz.Desc("created_at"), sqlz.Desc("id")).Limit(int64(limit)).
+		GetAllContext(ctx, &posts)
+	if err == nil {
+		err = s.appendDetailsMult(ctx, posts)
+	}
+	return posts, err
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// The DSN of a database with the schema of init/schema.sql, for example
+// user:password@tcp(localhost:3306)/innoserver?parseTime=true
+const dsnVariable = "INNOSERVER_TEST_DSN"
+
+// The number of posts of a listing, each with some options, media and
+// reactions
+const benchmarkPosts = 50
+
+// openTestDB connects to the test database and skips if none is configured
+func openTestDB(b *testing.B) *sqlx.DB {
+	dsn := os.Getenv(dsnVariable)
+	if dsn == "" {
+		b.Skip(dsnVariable + " is not set")
+	}
+	db, err := sqlx.Connect("mysql", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+// seedPosts stores a user with the benchmark posts and returns the id of the
+// user, removing the user removes everything seeded
+func seedPosts(ctx context.Context, db *sqlx.DB) (int64, error) {
+	uid := uuid.New().String()
+	res, err := sqlz.Newx(db).InsertInto("users").Columns("name", "email", "imei").
+		Values("benchmark", uid+"@example.com", "").ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	userID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < benchmarkPosts; i++ {
+		postUid := fmt.Sprintf("%s-%d", uid, i)
+		res, err := sqlz.Newx(db).InsertInto("posts").
+			Columns("title", "user_id", "path", "method", "type", "unique_id", "content").
+			Values(fmt.Sprintf("post %d", i), userID, "", 1, 0, postUid, "").ExecContext(ctx)
+		if err != nil {
+			return userID, err
+		}
+		postID, err := res.LastInsertId()
+		if err != nil {
+			return userID, err
+		}
+		for j := 0; j < 3; j++ {
+			_, err := sqlz.Newx(db).InsertInto("options").Columns("post_uid", "opt_key", "opt_value").
+				Values(postUid, fmt.Sprintf("key%d", j), "value").ExecContext(ctx)
+			if err != nil {
+				return userID, err
+			}
+			_, err = sqlz.Newx(db).InsertInto("post_media").Columns("post_id", "position", "path", "type").
+				Values(postID, j, fmt.Sprintf("/media/%s-%d", postUid, j), 0).ExecContext(ctx)
+			if err != nil {
+				return userID, err
+			}
+		}
+		_, err = sqlz.Newx(db).InsertInto("reactions").Columns("post_id", "user_id", "kind").
+			Values(postID, userID, "like").ExecContext(ctx)
+		if err != nil {
+			return userID, err
+		}
+	}
+	return userID, nil
+}
+
+// BenchmarkAppendDetails compares loading the details of a listing post by
+// post with loading them in batches
+func BenchmarkAppendDetails(b *testing.B) {
+	db := openTestDB(b)
+	defer db.Close()
+	ctx := context.Background()
+	repo, err := NewPostRepository(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer repo.Close()
+	userID, err := seedPosts(ctx, db)
+	defer sqlz.Newx(db).DeleteFrom("users").Where(sqlz.Eq("id", userID)).ExecContext(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	posts := []*model.Post{}
+	if err := repo.selectByUserID.SelectContext(ctx, &posts, userID); err != nil {
+		b.Fatal(err)
+	}
+	if len(posts) != benchmarkPosts {
+		b.Fatalf("seeded %d posts, want %d", len(posts), benchmarkPosts)
+	}
+	reset := func() {
+		for _, post := range posts {
+			post.Options, post.Media, post.Reactions = nil, nil, nil
+		}
+	}
+
+	b.Run("per post", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			reset()
+			for _, post := range posts {
+				if err := repo.appendDetails(ctx, post); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			reset()
+			if err := repo.appendDetailsMult(ctx, posts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
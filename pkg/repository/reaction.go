@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type reactionRepository struct {
+	add          *sqlx.Stmt
+	remove       *sqlx.Stmt
+	selectByPost *sqlx.Stmt
+}
+
+func NewReactionRepository(db *sqlx.DB) (*reactionRepository, error) {
+	ctx := context.Background()
+	add, _ := sqlz.Newx(db).InsertInto("reactions").Columns("post_id", "user_id", "kind").
+		Values("?", "?", "?").ToSQL(false)
+	add += " ON DUPLICATE KEY UPDATE kind = kind"
+
+	remove, _ := sqlz.Newx(db).DeleteFrom("reactions").Where(sqlz.Eq("post_id", "?"),
+		sqlz.Eq("user_id", "?"), sqlz.Eq("kind", "?")).ToSQL(false)
+
+	selectByPost, _ := sqlz.Newx(db).Select("r.*", "u.name AS user").From("reactions r").
+		InnerJoin("users u", sqlz.Eq("u.id", sqlz.Indirect("r.user_id"))).
+		Where(sqlz.Eq("r.post_id", "?")).OrderBy(sqlz.Asc("r.created_at")).ToSQL(false)
+
+	ctxAdd, err := db.PreparexContext(ctx, add)
+	if err != nil {
+		return nil, err
+	}
+	ctxRemove, err := db.PreparexContext(ctx, remove)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectByPost, err := db.PreparexContext(ctx, selectByPost)
+	if err != nil {
+		return nil, err
+	}
+	return &reactionRepository{
+		add:          ctxAdd,
+		remove:       ctxRemove,
+		selectByPost: ctxSelectByPost,
+	}, err
+}
+
+func (s *reactionRepository) Close() error {
+	var errorOccured error
+	if err := s.add.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.remove.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectByPost.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+// Add stores the reaction, reacting twice with the same kind has no effect
+func (s *reactionRepository) Add(ctx context.Context, reaction *model.Reaction) error {
+	_, err := s.add.ExecContext(ctx, reaction.PostID, reaction.UserID, reaction.Kind)
+	return err
+}
+
+func (s *reactionRepository) Remove(ctx context.Context, reaction *model.Reaction) error {
+	_, err := s.remove.ExecContext(ctx, reaction.PostID, reaction.UserID, reaction.Kind)
+	return err
+}
+
+func (s *reactionRepository) SelectByPost(ctx context.Context, post *model.Post) ([]*model.Reaction, error) {
+	reactions := []*model.Reaction{}
+	err := s.selectByPost.SelectContext(ctx, &reactions, post.ID)
+	return reactions, err
+}
@@ -1 +1,25 @@
 package repository
+
+import (
+	"github.com/ido50/sqlz"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// feedConditions restricts a feed query to the groups and to the items
+// following the cursor, the columns are those of the group, id and creation
+// time of the items
+func feedConditions(group, id, created string, groupIDs []int, before *model.FeedCursor) []sqlz.WhereCondition {
+	ids := make([]interface{}, len(groupIDs))
+	for i, groupID := range groupIDs {
+		ids[i] = groupID
+	}
+	conditions := []sqlz.WhereCondition{sqlz.In(group, ids...)}
+	if before != nil {
+		conditions = append(conditions, sqlz.Or(
+			sqlz.Lt(created, before.Time),
+			sqlz.And(sqlz.Eq(created, before.Time), sqlz.Lt(id, before.ID)),
+		))
+	}
+	return conditions
+}
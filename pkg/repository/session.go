@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type sessionRepository struct {
+	db                  *sqlx.DB
+	persist             *sqlx.Stmt
+	getByUid            *sqlx.Stmt
+	updateState         *sqlx.NamedStmt
+	selectExpired       *sqlx.Stmt
+	lockState           *sqlx.Stmt
+	countParticipants   *sqlx.Stmt
+	addParticipant      *sqlx.Stmt
+	selectParticipants  *sqlx.Stmt
+	addContribution     *sqlx.Stmt
+	selectContributions *sqlx.Stmt
+}
+
+func NewSessionRepository(db *sqlx.DB) (*sessionRepository, error) {
+	ctx := context.Background()
+	persist, _ := sqlz.Newx(db).InsertInto("sessions").Columns("unique_id", "post_id",
+		"facilitator_id", "group_id", "method", "round_duration", "rounds", "ideas_per_round").
+		Values("?", "?", "?", "?", "?", "?", "?", "?").ToSQL(false)
+
+	getByUid, _ := sqlz.Newx(db).Select("*").From("sessions").
+		Where(sqlz.Eq("unique_id", "?")).ToSQL(false)
+
+	// the columns of updates with several values come in random order, so
+	// their values are bound by name
+	updateState, _ := sqlz.Newx(db).Update("sessions").Set("rounds", sqlz.Indirect(":rounds")).
+		Set("current_round", sqlz.Indirect(":current_round")).
+		Set("round_deadline", sqlz.Indirect(":round_deadline")).Set("state", sqlz.Indirect(":state")).
+		Where(sqlz.Eq("id", sqlz.Indirect(":id")), sqlz.Eq("state", sqlz.Indirect(":previous_state")),
+			sqlz.Eq("current_round", sqlz.Indirect(":previous_round"))).ToSQL(false)
+
+	selectExpired, _ := sqlz.Newx(db).Select("*").From("sessions").
+		Where(sqlz.Eq("state", "?"), sqlz.Lte("round_deadline", "?")).ToSQL(false)
+
+	lockState, _ := sqlz.Newx(db).Select("state").From("sessions").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+	lockState += " FOR UPDATE"
+
+	countParticipants, _ := sqlz.Newx(db).Select("COUNT(*)").From("session_participants").
+		Where(sqlz.Eq("session_id", "?")).ToSQL(false)
+
+	addParticipant, _ := sqlz.Newx(db).InsertInto("session_participants").
+		Columns("session_id", "user_id", "seat").Values("?", "?", "?").ToSQL(false)
+
+	selectParticipants, _ := sqlz.Newx(db).Select("sp.*", "u.name").From("session_participants sp").
+		InnerJoin("users u", sqlz.Eq("u.id", sqlz.Indirect("sp.user_id"))).
+		Where(sqlz.Eq("sp.session_id", "?")).OrderBy(sqlz.Asc("sp.seat")).ToSQL(false)
+
+	addContribution, _ := sqlz.Newx(db).InsertInto("session_contributions").
+		Columns("session_id", "post_id", "user_id", "round", "sheet", "idea").
+		Values("?", "?", "?", "?", "?", "?").ToSQL(false)
+
+	selectContributions, _ := sqlz.Newx(db).Select("sc.*", "p.unique_id AS post_uid").
+		From("session_contributions sc").
+		InnerJoin("posts p", sqlz.Eq("p.id", sqlz.Indirect("sc.post_id"))).
+		Where(sqlz.Eq("sc.session_id", "?")).
+		OrderBy(sqlz.Asc("sc.round"), sqlz.Asc("sc.sheet"), sqlz.Asc("sc.idea")).ToSQL(false)
+
+	ctxPersist, err := db.PreparexContext(ctx, persist)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetByUid, err := db.PreparexContext(ctx, getByUid)
+	if err != nil {
+		return nil, err
+	}
+	ctxUpdateState, err := db.PrepareNamedContext(ctx, updateState)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectExpired, err := db.PreparexContext(ctx, selectExpired)
+	if err != nil {
+		return nil, err
+	}
+	ctxLockState, err := db.PreparexContext(ctx, lockState)
+	if err != nil {
+		return nil, err
+	}
+	ctxCountParticipants, err := db.PreparexContext(ctx, countParticipants)
+	if err != nil {
+		return nil, err
+	}
+	ctxAddParticipant, err := db.PreparexContext(ctx, addParticipant)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectParticipants, err := db.PreparexContext(ctx, selectParticipants)
+	if err != nil {
+		return nil, err
+	}
+	ctxAddContribution, err := db.PreparexContext(ctx, addContribution)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectContributions, err := db.PreparexContext(ctx, selectContributions)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionRepository{
+		db:                  db,
+		persist:             ctxPersist,
+		getByUid:            ctxGetByUid,
+		updateState:         ctxUpdateState,
+		selectExpired:       ctxSelectExpired,
+		lockState:           ctxLockState,
+		countParticipants:   ctxCountParticipants,
+		addParticipant:      ctxAddParticipant,
+		selectParticipants:  ctxSelectParticipants,
+		addContribution:     ctxAddContribution,
+		selectContributions: ctxSelectContributions,
+	}, err
+}
+
+func (s *sessionRepository) Close() error {
+	var errorOccured error
+	if err := s.persist.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getByUid.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.updateState.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectExpired.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.lockState.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.countParticipants.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.addParticipant.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectParticipants.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.addContribution.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectContributions.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+func (s *sessionRepository) Persist(ctx context.Context, session *model.Session) error {
+	_, err := s.persist.ExecContext(ctx, session.UniqueID, session.PostID, session.FacilitatorID,
+		session.GroupID, session.Method, session.RoundDuration, session.Rounds, session.IdeasPerRound)
+	return err
+}
+
+func (s *sessionRepository) GetByUid(ctx context.Context, uid string) (*model.Session, error) {
+	session := &model.Session{}
+	err := s.getByUid.GetContext(ctx, session, uid)
+	return session, err
+}
+
+func (s *sessionRepository) UniqueIdExists(ctx context.Context, uid string) (bool, error) {
+	if _, err := s.GetByUid(ctx, uid); err != nil && err != sql.ErrNoRows {
+		return true, err
+	}
+	return false, nil
+}
+
+// UpdateState stores the rounds and state of the session, if the stored
+// session is still in the state and round it was read with. It reports
+// whether the session was updated, if not it was changed meanwhile.
+func (s *sessionRepository) UpdateState(ctx context.Context, session *model.Session,
+	previousState, previousRound int) (bool, error) {
+	res, err := s.updateState.ExecContext(ctx, map[string]interface{}{
+		"rounds":         session.Rounds,
+		"current_round":  session.CurrentRound,
+		"round_deadline": session.RoundDeadline,
+		"state":          session.State,
+		"id":             session.ID,
+		"previous_state": previousState,
+		"previous_round": previousRound,
+	})
+	if err != nil {
+		return false, err
+	}
+	updated, err := res.RowsAffected()
+	return updated == 1, err
+}
+
+// SelectExpired returns all running sessions whose round deadline passed
+func (s *sessionRepository) SelectExpired(ctx context.Context, now time.Time) ([]*model.Session, error) {
+	sessions := []*model.Session{}
+	err := s.selectExpired.SelectContext(ctx, &sessions, model.SessionRunning, now)
+	return sessions, err
+}
+
+// AddParticipant seats the participant on the next free seat of the session.
+// The session is locked meanwhile, so parallel joins get different seats. It
+// reports whether the participant was added, which fails once the session
+// isn't open anymore.
+func (s *sessionRepository) AddParticipant(ctx context.Context, participant *model.SessionParticipant) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	added, err := func() (bool, error) {
+		var state int
+		if err := tx.StmtxContext(ctx, s.lockState).GetContext(ctx, &state, participant.SessionID); err != nil {
+			return false, err
+		}
+		if state != model.SessionOpen {
+			return false, nil
+		}
+		err := tx.StmtxContext(ctx, s.countParticipants).GetContext(ctx, &participant.Seat, participant.SessionID)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.StmtxContext(ctx, s.addParticipant).ExecContext(ctx,
+			participant.SessionID, participant.UserID, participant.Seat)
+		return err == nil, err
+	}()
+	if err != nil || !added {
+		tx.Rollback()
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *sessionRepository) SelectParticipants(ctx context.Context, session *model.Session) ([]*model.SessionParticipant, error) {
+	participants := []*model.SessionParticipant{}
+	err := s.selectParticipants.SelectContext(ctx, &participants, session.ID)
+	return participants, err
+}
+
+func (s *sessionRepository) AddContribution(ctx context.Context, contribution *model.SessionContribution) error {
+	_, err := s.addContribution.ExecContext(ctx, contribution.SessionID, contribution.PostID,
+		contribution.UserID, contribution.Round, contribution.Sheet, contribution.Idea)
+	return err
+}
+
+func (s *sessionRepository) SelectContributions(ctx context.Context, session *model.Session) ([]*model.SessionContribution, error) {
+	contributions := []*model.SessionContribution{}
+	err := s.selectContributions.SelectContext(ctx, &contributions, session.ID)
+	return contributions, err
+}
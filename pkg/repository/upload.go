@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type uploadRepository struct {
+	persist        *sqlx.Stmt
+	getByUid       *sqlx.Stmt
+	updateReceived *sqlx.Stmt
+	remove         *sqlx.Stmt
+	setCompleting  *sqlx.Stmt
+	selectBefore   *sqlx.Stmt
+}
+
+func NewUploadRepository(db *sqlx.DB) (*uploadRepository, error) {
+	ctx := context.Background()
+	persist, _ := sqlz.Newx(db).InsertInto("uploads").Columns("unique_id", "user_id",
+		"group_id", "parent_id", "title", "method", "type", "position", "prompt", "options",
+		"extension", "size").Values("?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?").ToSQL(false)
+
+	getByUid, _ := sqlz.Newx(db).Select("*").From("uploads").
+		Where(sqlz.Eq("unique_id", "?")).ToSQL(false)
+
+	updateReceived, _ := sqlz.Newx(db).Update("uploads").Set("received", "?").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+
+	remove, _ := sqlz.Newx(db).DeleteFrom("uploads").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+
+	setCompleting, _ := sqlz.Newx(db).Update("uploads").Set("completing", "?").
+		Where(sqlz.Eq("id", "?"), sqlz.Eq("completing", "?")).ToSQL(false)
+
+	selectBefore, _ := sqlz.Newx(db).Select("*").From("uploads").
+		Where(sqlz.Lt("created_at", "?")).ToSQL(false)
+
+	ctxPersist, err := db.PreparexContext(ctx, persist)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetByUid, err := db.PreparexContext(ctx, getByUid)
+	if err != nil {
+		return nil, err
+	}
+	ctxUpdateReceived, err := db.PreparexContext(ctx, updateReceived)
+	if err != nil {
+		return nil, err
+	}
+	ctxRemove, err := db.PreparexContext(ctx, remove)
+	if err != nil {
+		return nil, err
+	}
+	ctxSetCompleting, err := db.PreparexContext(ctx, setCompleting)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectBefore, err := db.PreparexContext(ctx, selectBefore)
+	if err != nil {
+		return nil, err
+	}
+	return &uploadRepository{
+		persist:        ctxPersist,
+		getByUid:       ctxGetByUid,
+		updateReceived: ctxUpdateReceived,
+		remove:         ctxRemove,
+		setCompleting:  ctxSetCompleting,
+		selectBefore:   ctxSelectBefore,
+	}, err
+}
+
+func (s *uploadRepository) Close() error {
+	var errorOccured error
+	if err := s.persist.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getByUid.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.updateReceived.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.remove.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.setCompleting.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectBefore.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+func (s *uploadRepository) Persist(ctx context.Context, upload *model.Upload) error {
+	_, err := s.persist.ExecContext(ctx, upload.UniqueID, upload.UserID, upload.GroupID,
+		upload.ParentID, upload.Title, upload.Method, upload.Type, upload.Position, upload.Prompt,
+		upload.Options, upload.Extension,
+		upload.Size)
+	return err
+}
+
+func (s *uploadRepository) GetByUid(ctx context.Context, uid string) (*model.Upload, error) {
+	upload := &model.Upload{}
+	err := s.getByUid.GetContext(ctx, upload, uid)
+	return upload, err
+}
+
+func (s *uploadRepository) UniqueIdExists(ctx context.Context, uid string) (bool, error) {
+	if _, err := s.GetByUid(ctx, uid); err != nil && err != sql.ErrNoRows {
+		return true, err
+	}
+	return false, nil
+}
+
+func (s *uploadRepository) UpdateReceived(ctx context.Context, upload *model.Upload) error {
+	_, err := s.updateReceived.ExecContext(ctx, upload.Received, upload.ID)
+	return err
+}
+
+func (s *uploadRepository) Remove(ctx context.Context, upload *model.Upload) error {
+	_, err := s.remove.ExecContext(ctx, upload.ID)
+	return err
+}
+
+// Claim marks the upload as completing and reports whether it wasn't
+// already, so only one of several concurrent completions of the upload goes on
+func (s *uploadRepository) Claim(ctx context.Context, upload *model.Upload) (bool, error) {
+	res, err := s.setCompleting.ExecContext(ctx, true, upload.ID, false)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := res.RowsAffected()
+	return claimed == 1, err
+}
+
+// Release takes back the claim of a failed completion, so it can be retried
+func (s *uploadRepository) Release(ctx context.Context, upload *model.Upload) error {
+	_, err := s.setCompleting.ExecContext(ctx, false, upload.ID, true)
+	return err
+}
+
+func (s *uploadRepository) SelectCreatedBefore(ctx context.Context, before time.Time) ([]*model.Upload, error) {
+	uploads := []*model.Upload{}
+	err := s.selectBefore.SelectContext(ctx, &uploads, before)
+	return uploads, err
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type votingRepository struct {
+	db            *sqlx.DB
+	persist       *sqlx.Stmt
+	getByUid      *sqlx.Stmt
+	updateState   *sqlx.Stmt
+	castVote      *sqlx.Stmt
+	retractVote   *sqlx.Stmt
+	selectByUser  *sqlx.Stmt
+	selectTallies *sqlx.Stmt
+	selectVoters  *sqlx.Stmt
+	lockVoting    *sqlx.Stmt
+	sumOtherVotes *sqlx.Stmt
+}
+
+func NewVotingRepository(db *sqlx.DB) (*votingRepository, error) {
+	ctx := context.Background()
+	persist, _ := sqlz.Newx(db).InsertInto("votings").Columns("unique_id", "post_id",
+		"group_id", "facilitator_id", "mode", "votes_per_user").
+		Values("?", "?", "?", "?", "?", "?").ToSQL(false)
+
+	getByUid, _ := sqlz.Newx(db).Select("*").From("votings").
+		Where(sqlz.Eq("unique_id", "?")).ToSQL(false)
+
+	updateState, _ := sqlz.Newx(db).Update("votings").Set("state", "?").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+
+	castVote, _ := sqlz.Newx(db).InsertInto("votes").Columns("voting_id", "user_id",
+		"post_id", "value").Values("?", "?", "?", "?").ToSQL(false)
+	castVote += " ON DUPLICATE KEY UPDATE value = VALUES(value)"
+
+	retractVote, _ := sqlz.Newx(db).DeleteFrom("votes").Where(sqlz.Eq("voting_id", "?"),
+		sqlz.Eq("user_id", "?"), sqlz.Eq("post_id", "?")).ToSQL(false)
+
+	selectByUser, _ := sqlz.Newx(db).Select("v.*", "p.unique_id AS post_uid").From("votes v").
+		InnerJoin("posts p", sqlz.Eq("p.id", sqlz.Indirect("v.post_id"))).
+		Where(sqlz.Eq("v.voting_id", "?"), sqlz.Eq("v.user_id", "?")).ToSQL(false)
+
+	selectTallies, _ := sqlz.Newx(db).Select("post_id", "SUM(value) AS total", "COUNT(*) AS voters").
+		From("votes").Where(sqlz.Eq("voting_id", "?")).GroupBy("post_id").ToSQL(false)
+
+	selectVoters, _ := sqlz.Newx(db).Select("DISTINCT user_id").From("votes").
+		Where(sqlz.Eq("voting_id", "?")).ToSQL(false)
+
+	lockVoting, _ := sqlz.Newx(db).Select("id").From("votings").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+	lockVoting += " FOR UPDATE"
+
+	sumOtherVotes, _ := sqlz.Newx(db).Select("COALESCE(SUM(value), 0)").From("votes").
+		Where(sqlz.Eq("voting_id", "?"), sqlz.Eq("user_id", "?"), sqlz.Ne("post_id", "?")).ToSQL(false)
+
+	ctxPersist, err := db.PreparexContext(ctx, persist)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetByUid, err := db.PreparexContext(ctx, getByUid)
+	if err != nil {
+		return nil, err
+	}
+	ctxUpdateState, err := db.PreparexContext(ctx, updateState)
+	if err != nil {
+		return nil, err
+	}
+	ctxCastVote, err := db.PreparexContext(ctx, castVote)
+	if err != nil {
+		return nil, err
+	}
+	ctxRetractVote, err := db.PreparexContext(ctx, retractVote)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectByUser, err := db.PreparexContext(ctx, selectByUser)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectTallies, err := db.PreparexContext(ctx, selectTallies)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectVoters, err := db.PreparexContext(ctx, selectVoters)
+	if err != nil {
+		return nil, err
+	}
+	ctxLockVoting, err := db.PreparexContext(ctx, lockVoting)
+	if err != nil {
+		return nil, err
+	}
+	ctxSumOtherVotes, err := db.PreparexContext(ctx, sumOtherVotes)
+	if err != nil {
+		return nil, err
+	}
+	return &votingRepository{
+		db:            db,
+		persist:       ctxPersist,
+		getByUid:      ctxGetByUid,
+		updateState:   ctxUpdateState,
+		castVote:      ctxCastVote,
+		retractVote:   ctxRetractVote,
+		selectByUser:  ctxSelectByUser,
+		selectTallies: ctxSelectTallies,
+		selectVoters:  ctxSelectVoters,
+		lockVoting:    ctxLockVoting,
+		sumOtherVotes: ctxSumOtherVotes,
+	}, err
+}
+
+func (s *votingRepository) Close() error {
+	var errorOccured error
+	if err := s.persist.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getByUid.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.updateState.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.castVote.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.retractVote.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectByUser.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectTallies.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectVoters.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.lockVoting.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.sumOtherVotes.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+func (s *votingRepository) Persist(ctx context.Context, voting *model.Voting) error {
+	_, err := s.persist.ExecContext(ctx, voting.UniqueID, voting.PostID, voting.GroupID,
+		voting.FacilitatorID, voting.Mode, voting.VotesPerUser)
+	return err
+}
+
+func (s *votingRepository) GetByUid(ctx context.Context, uid string) (*model.Voting, error) {
+	voting := &model.Voting{}
+	err := s.getByUid.GetContext(ctx, voting, uid)
+	return voting, err
+}
+
+func (s *votingRepository) UniqueIdExists(ctx context.Context, uid string) (bool, error) {
+	if _, err := s.GetByUid(ctx, uid); err != nil && err != sql.ErrNoRows {
+		return true, err
+	}
+	return false, nil
+}
+
+func (s *votingRepository) UpdateState(ctx context.Context, voting *model.Voting) error {
+	_, err := s.updateState.ExecContext(ctx, voting.State, voting.ID)
+	return err
+}
+
+// CastVote stores the vote, replacing an earlier vote of the user on the
+// post, and returns the votes the user spent in the voting with it. If they
+// exceed the budget the vote isn't stored, a budget of 0 means no limit. The
+// voting is locked meanwhile, so parallel votes can't overdraw the budget.
+func (s *votingRepository) CastVote(ctx context.Context, vote *model.Vote, budget int) (int, error) {
+	used := 0
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var id int
+		if err := tx.StmtxContext(ctx, s.lockVoting).GetContext(ctx, &id, vote.VotingID); err != nil {
+			return err
+		}
+		err := tx.StmtxContext(ctx, s.sumOtherVotes).GetContext(ctx, &used,
+			vote.VotingID, vote.UserID, vote.PostID)
+		if err != nil {
+			return err
+		}
+		used += vote.Value
+		if budget > 0 && used > budget {
+			return nil
+		}
+		_, err = tx.StmtxContext(ctx, s.castVote).ExecContext(ctx,
+			vote.VotingID, vote.UserID, vote.PostID, vote.Value)
+		return err
+	})
+	return used, err
+}
+
+// inTx runs f within a transaction, which is rolled back if f fails
+func (s *votingRepository) inTx(ctx context.Context, f func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *votingRepository) RetractVote(ctx context.Context, vote *model.Vote) error {
+	_, err := s.retractVote.ExecContext(ctx, vote.VotingID, vote.UserID, vote.PostID)
+	return err
+}
+
+func (s *votingRepository) SelectVotesOfUser(ctx context.Context, voting *model.Voting,
+	user *model.User) ([]*model.Vote, error) {
+	votes := []*model.Vote{}
+	err := s.selectByUser.SelectContext(ctx, &votes, voting.ID, user.ID)
+	return votes, err
+}
+
+// SelectTallies sums up the votes per post
+func (s *votingRepository) SelectTallies(ctx context.Context, voting *model.Voting) ([]*model.VoteTally, error) {
+	tallies := []*model.VoteTally{}
+	err := s.selectTallies.SelectContext(ctx, &tallies, voting.ID)
+	return tallies, err
+}
+
+// SelectVoters returns the ids of all users who voted
+func (s *votingRepository) SelectVoters(ctx context.Context, voting *model.Voting) ([]int, error) {
+	voters := []int{}
+	err := s.selectVoters.SelectContext(ctx, &voters, voting.ID)
+	return voters, err
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/ido50/sqlz"
+	"github.com/jmoiron/sqlx"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type webhookRepository struct {
+	persist          *sqlx.Stmt
+	getByUid         *sqlx.Stmt
+	selectByGroup    *sqlx.Stmt
+	remove           *sqlx.Stmt
+	addDelivery      *sqlx.Stmt
+	updateDelivery   *sqlx.NamedStmt
+	selectDue        *sqlx.Stmt
+	selectDeliveries *sqlx.Stmt
+}
+
+func NewWebhookRepository(db *sqlx.DB) (*webhookRepository, error) {
+	ctx := context.Background()
+	limit := " LIMIT ?"
+	persist, _ := sqlz.Newx(db).InsertInto("webhooks").Columns("unique_id", "group_id",
+		"url", "secret", "events").Values("?", "?", "?", "?", "?").ToSQL(false)
+
+	getByUid, _ := sqlz.Newx(db).Select("*").From("webhooks").
+		Where(sqlz.Eq("unique_id", "?")).ToSQL(false)
+
+	selectByGroup, _ := sqlz.Newx(db).Select("*").From("webhooks").
+		Where(sqlz.Eq("group_id", "?")).OrderBy(sqlz.Asc("created_at")).ToSQL(false)
+
+	remove, _ := sqlz.Newx(db).DeleteFrom("webhooks").
+		Where(sqlz.Eq("id", "?")).ToSQL(false)
+
+	addDelivery, _ := sqlz.Newx(db).InsertInto("webhook_deliveries").Columns("unique_id", "webhook_id",
+		"event_type", "payload", "state", "attempts", "next_attempt", "last_status", "last_error",
+		"delivered_at").Values("?", "?", "?", "?", "?", "?", "?", "?", "?", "?").ToSQL(false)
+
+	// the columns of updates with several values come in random order, so
+	// the delivery is bound by name
+	updateDelivery, _ := sqlz.Newx(db).Update("webhook_deliveries").Set("state", sqlz.Indirect(":state")).
+		Set("attempts", sqlz.Indirect(":attempts")).Set("next_attempt", sqlz.Indirect(":next_attempt")).
+		Set("last_status", sqlz.Indirect(":last_status")).Set("last_error", sqlz.Indirect(":last_error")).
+		Set("delivered_at", sqlz.Indirect(":delivered_at")).
+		Where(sqlz.Eq("id", sqlz.Indirect(":id"))).ToSQL(false)
+
+	selectDue, _ := sqlz.Newx(db).Select("d.*", "w.url", "w.secret").From("webhook_deliveries d").
+		InnerJoin("webhooks w", sqlz.Eq("w.id", sqlz.Indirect("d.webhook_id"))).
+		Where(sqlz.Eq("d.state", "?"), sqlz.Lte("d.next_attempt", "?")).
+		OrderBy(sqlz.Asc("d.next_attempt")).ToSQL(false)
+
+	selectDeliveries, _ := sqlz.Newx(db).Select("*").From("webhook_deliveries").
+		Where(sqlz.Eq("webhook_id", "?")).OrderBy(sqlz.Desc("created_at"), sqlz.Desc("id")).ToSQL(false)
+
+	ctxPersist, err := db.PreparexContext(ctx, persist)
+	if err != nil {
+		return nil, err
+	}
+	ctxGetByUid, err := db.PreparexContext(ctx, getByUid)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectByGroup, err := db.PreparexContext(ctx, selectByGroup)
+	if err != nil {
+		return nil, err
+	}
+	ctxRemove, err := db.PreparexContext(ctx, remove)
+	if err != nil {
+		return nil, err
+	}
+	ctxAddDelivery, err := db.PreparexContext(ctx, addDelivery)
+	if err != nil {
+		return nil, err
+	}
+	ctxUpdateDelivery, err := db.PrepareNamedContext(ctx, updateDelivery)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectDue, err := db.PreparexContext(ctx, selectDue+limit)
+	if err != nil {
+		return nil, err
+	}
+	ctxSelectDeliveries, err := db.PreparexContext(ctx, selectDeliveries+limit)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookRepository{
+		persist:          ctxPersist,
+		getByUid:         ctxGetByUid,
+		selectByGroup:    ctxSelectByGroup,
+		remove:           ctxRemove,
+		addDelivery:      ctxAddDelivery,
+		updateDelivery:   ctxUpdateDelivery,
+		selectDue:        ctxSelectDue,
+		selectDeliveries: ctxSelectDeliveries,
+	}, err
+}
+
+func (s *webhookRepository) Close() error {
+	var errorOccured error
+	if err := s.persist.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.getByUid.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectByGroup.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.remove.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.addDelivery.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.updateDelivery.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectDue.Close(); err != nil {
+		errorOccured = err
+	}
+	if err := s.selectDeliveries.Close(); err != nil {
+		errorOccured = err
+	}
+	return errorOccured
+}
+
+func (s *webhookRepository) Persist(ctx context.Context, webhook *model.Webhook) error {
+	_, err := s.persist.ExecContext(ctx, webhook.UniqueID, webhook.GroupID, webhook.Url,
+		webhook.Secret, strings.Join(webhook.Events, ","))
+	return err
+}
+
+func (s *webhookRepository) GetByUid(ctx context.Context, uid string) (*model.Webhook, error) {
+	webhook := &model.Webhook{}
+	err := s.getByUid.GetContext(ctx, webhook, uid)
+	webhook.Events = strings.Split(webhook.EventList, ",")
+	return webhook, err
+}
+
+func (s *webhookRepository) UniqueIdExists(ctx context.Context, uid string) (bool, error) {
+	if _, err := s.GetByUid(ctx, uid); err != nil && err != sql.ErrNoRows {
+		return true, err
+	}
+	return false, nil
+}
+
+func (s *webhookRepository) SelectByGroup(ctx context.Context, groupID int) ([]*model.Webhook, error) {
+	webhooks := []*model.Webhook{}
+	err := s.selectByGroup.SelectContext(ctx, &webhooks, groupID)
+	for _, webhook := range webhooks {
+		webhook.Events = strings.Split(webhook.EventList, ",")
+	}
+	return webhooks, err
+}
+
+// Remove deletes the webhook together with its deliveries
+func (s *webhookRepository) Remove(ctx context.Context, webhook *model.Webhook) error {
+	_, err := s.remove.ExecContext(ctx, webhook.ID)
+	return err
+}
+
+func (s *webhookRepository) AddDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	res, err := s.addDelivery.ExecContext(ctx, delivery.UniqueID, delivery.WebhookID, delivery.EventType,
+		delivery.Payload, delivery.State, delivery.Attempts, delivery.NextAttempt, delivery.LastStatus,
+		delivery.LastError, delivery.DeliveredAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	delivery.ID = int(id)
+	return err
+}
+
+// UpdateDelivery stores the outcome of a delivery attempt
+func (s *webhookRepository) UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	_, err := s.updateDelivery.ExecContext(ctx, delivery)
+	return err
+}
+
+// SelectDue returns pending deliveries whose next attempt is due, together
+// with the url and secret of their webhook
+func (s *webhookRepository) SelectDue(ctx context.Context, now time.Time, limit uint64) ([]*model.WebhookDelivery, error) {
+	deliveries := []*model.WebhookDelivery{}
+	err := s.selectDue.SelectContext(ctx, &deliveries, model.DeliveryPending, now, limit)
+	return deliveries, err
+}
+
+// SelectDeliveries returns the latest deliveries of the webhook
+func (s *webhookRepository) SelectDeliveries(ctx context.Context, webhook *model.Webhook,
+	limit uint64) ([]*model.WebhookDelivery, error) {
+	deliveries := []*model.WebhookDelivery{}
+	err := s.selectDeliveries.SelectContext(ctx, &deliveries, webhook.ID, limit)
+	return deliveries, err
+}
@@ -0,0 +1,125 @@
+// Package session implements the round logic of timed creativity sessions
+// like the 6-3-5 method. The state is kept in the database only, a session
+// therefore continues after a restart of the server.
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type repository interface {
+	SelectExpired(ctx context.Context, now time.Time) ([]*model.Session, error)
+	UpdateState(ctx context.Context, session *model.Session, previousState, previousRound int) (bool, error)
+}
+
+// Sheet returns the sheet the participant on seat works on in round. Seats
+// and sheets start with 0, rounds with 1, so every participant starts on
+// their own sheet and passes it on to the next seat afterwards.
+func Sheet(seat, round, participants int) int {
+	if participants == 0 {
+		return 0
+	}
+	return (seat + round - 1) % participants
+}
+
+// Start opens the first round. Sessions without a number of rounds get one
+// round per participant, so each sheet passes every participant once.
+func Start(session *model.Session, participants int, now time.Time) {
+	if session.Rounds <= 0 {
+		session.Rounds = participants
+	}
+	session.State = model.SessionRunning
+	nextRound(session, now)
+}
+
+// Advance closes the current round if its deadline passed and opens the
+// next one, or closes the session after the last round. It reports whether
+// the session changed.
+func Advance(session *model.Session, now time.Time) bool {
+	if session.State != model.SessionRunning || session.RoundDeadline == nil ||
+		now.Before(*session.RoundDeadline) {
+		return false
+	}
+	if session.CurrentRound >= session.Rounds {
+		Close(session)
+		return true
+	}
+	nextRound(session, now)
+	return true
+}
+
+// Close ends the session, no further contributions are accepted
+func Close(session *model.Session) {
+	session.State = model.SessionClosed
+	session.RoundDeadline = nil
+}
+
+func nextRound(session *model.Session, now time.Time) {
+	deadline := now.Add(time.Duration(session.RoundDuration) * time.Second)
+	session.CurrentRound++
+	session.RoundDeadline = &deadline
+}
+
+// Clock advances all running sessions whose round deadline passed
+type Clock struct {
+	repo repository
+	log  *logrus.Entry
+}
+
+func NewClock(repo repository, log *logrus.Logger) *Clock {
+	return &Clock{
+		repo: repo,
+		log:  log.WithField("component", "session clock"),
+	}
+}
+
+// Run ticks periodically until the context is cancelled
+func (c *Clock) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Tick(ctx); err != nil {
+				c.log.WithError(err).Errorln("advancing sessions failed")
+			}
+		}
+	}
+}
+
+// Tick advances every expired session by one round
+func (c *Clock) Tick(ctx context.Context) error {
+	now := time.Now()
+	sessions, err := c.repo.SelectExpired(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		state, round := session.State, session.CurrentRound
+		if !Advance(session, now) {
+			continue
+		}
+		updated, err := c.repo.UpdateState(ctx, session, state, round)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			// a request advanced or closed the session meanwhile
+			c.log.WithField("session", session.UniqueID).Debugln("session changed meanwhile")
+			continue
+		}
+		c.log.WithFields(logrus.Fields{
+			"session": session.UniqueID,
+			"round":   session.CurrentRound,
+			"state":   session.State,
+		}).Debugln("session advanced")
+	}
+	return nil
+}
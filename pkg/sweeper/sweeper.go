@@ -0,0 +1,272 @@
+package sweeper
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// The directory all asset directories have to be located in, relative to
+// the working directory of the server
+const assetsRoot = "assets"
+
+type blobRepository interface {
+	SelectAll(ctx context.Context) ([]*model.Blob, error)
+	SelectReferences(ctx context.Context) ([]*model.Blob, error)
+	UpdateRefCount(ctx context.Context, blob *model.Blob, previous int) error
+	Remove(ctx context.Context, blob *model.Blob, previous int) error
+}
+
+type uploadRepository interface {
+	SelectCreatedBefore(ctx context.Context, before time.Time) ([]*model.Upload, error)
+	Remove(ctx context.Context, upload *model.Upload) error
+}
+
+// Report lists everything a sweep found (and removed, unless dry run is set)
+type Report struct {
+	OrphanedFiles  []string `json:"orphaned_files"`
+	StaleUploads   []string `json:"stale_uploads"`
+	FixedRefCounts []string `json:"fixed_ref_counts"`
+	FreedBytes     int64    `json:"freed_bytes"`
+}
+
+// Sweeper reconciles the asset directories with the files referenced by posts
+type Sweeper struct {
+	blobRepo   blobRepository
+	uploadRepo uploadRepository
+	config     *model.Config
+	log        *logrus.Entry
+
+	// DryRun only reports orphans without removing anything
+	DryRun bool
+	// GracePeriod protects recently written files, which may belong to
+	// uploads still in progress
+	GracePeriod time.Duration
+	// UploadExpiry is the time after which unfinished resumable uploads
+	// are discarded
+	UploadExpiry time.Duration
+}
+
+func New(blobRepo blobRepository, uploadRepo uploadRepository, config *model.Config,
+	log *logrus.Logger) *Sweeper {
+	sweeper := &Sweeper{
+		blobRepo:     blobRepo,
+		uploadRepo:   uploadRepo,
+		config:       config,
+		log:          log.WithField("component", "sweeper"),
+		GracePeriod:  time.Hour,
+		UploadExpiry: 24 * time.Hour,
+	}
+	if config.SweepGracePeriod > 0 {
+		sweeper.GracePeriod = time.Duration(config.SweepGracePeriod) * time.Second
+	}
+	if config.UploadExpiry > 0 {
+		sweeper.UploadExpiry = time.Duration(config.UploadExpiry) * time.Second
+	}
+	return sweeper
+}
+
+// Run sweeps periodically until the context is cancelled
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Sweep(ctx); err != nil {
+				s.log.WithError(err).Errorln("sweeping media files failed")
+			}
+		}
+	}
+}
+
+// Sweep removes stale uploads, corrects reference counts and deletes all
+// files of the asset directories which are referenced by no post
+// Nothing is removed if one of the directories is not configured or
+// located outside of the assets root.
+func (s *Sweeper) Sweep(ctx context.Context) (*Report, error) {
+	report := &Report{}
+	fTypes := []int{model.PostTypeImage, model.PostTypeVideo, model.PostTypeAudio}
+	dirs := map[int]string{}
+	for _, fType := range fTypes {
+		dir, err := assetDir(s.mediaPath(fType))
+		if err != nil {
+			return report, err
+		}
+		dirs[fType] = dir
+	}
+	uploadDir, err := assetDir(s.config.UploadPath)
+	if err != nil {
+		return report, err
+	}
+	threshold := time.Now().Add(-s.GracePeriod)
+	if err := s.sweepUploads(ctx, uploadDir, report); err != nil {
+		return report, err
+	}
+	referenced, err := s.reconcileBlobs(ctx, threshold, report)
+	if err != nil {
+		return report, err
+	}
+	for _, fType := range fTypes {
+		fType := fType
+		if err := s.sweepDir(dirs[fType], threshold, func(name string) bool {
+			return referenced[blobKey(fType, name)]
+		}, report); err != nil {
+			return report, err
+		}
+	}
+	s.log.WithFields(logrus.Fields{
+		"dry_run":          s.DryRun,
+		"orphaned_files":   len(report.OrphanedFiles),
+		"stale_uploads":    len(report.StaleUploads),
+		"fixed_ref_counts": len(report.FixedRefCounts),
+		"freed_bytes":      report.FreedBytes,
+	}).Infoln("media sweep finished")
+	return report, nil
+}
+
+// sweepUploads discards expired resumable uploads and partial files
+// which weren't written to for longer than the upload expiry
+func (s *Sweeper) sweepUploads(ctx context.Context, dir string, report *Report) error {
+	threshold := time.Now().Add(-s.UploadExpiry)
+	uploads, err := s.uploadRepo.SelectCreatedBefore(ctx, threshold)
+	if err != nil {
+		return err
+	}
+	for _, upload := range uploads {
+		report.StaleUploads = append(report.StaleUploads, upload.UniqueID)
+		if s.DryRun {
+			continue
+		}
+		if err := s.uploadRepo.Remove(ctx, upload); err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(dir, upload.UniqueID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return s.sweepDir(dir, threshold, func(name string) bool {
+		return false
+	}, report)
+}
+
+// reconcileBlobs corrects the reference counts of all blobs and removes
+// blobs which aren't referenced anymore. It returns the set of referenced files.
+func (s *Sweeper) reconcileBlobs(ctx context.Context, threshold time.Time,
+	report *Report) (map[string]bool, error) {
+	references, err := s.blobRepo.SelectReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	referenced := map[string]bool{}
+	counts := map[string]int{}
+	for _, ref := range references {
+		referenced[blobKey(ref.Type, ref.Path)] = true
+		counts[blobKey(ref.Type, ref.Path)] = ref.RefCount
+	}
+	blobs, err := s.blobRepo.SelectAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		key := blobKey(blob.Type, blob.Path)
+		if blob.UpdatedAt.After(threshold) {
+			// the reference may belong to a post which is just being created
+			referenced[key] = true
+			continue
+		}
+		if counts[key] == blob.RefCount {
+			continue
+		}
+		report.FixedRefCounts = append(report.FixedRefCounts, key)
+		if s.DryRun {
+			continue
+		}
+		// counts changed by the server meanwhile are corrected by the next sweep
+		previous := blob.RefCount
+		blob.RefCount = counts[key]
+		if blob.RefCount == 0 {
+			err = s.blobRepo.Remove(ctx, blob, previous)
+		} else {
+			err = s.blobRepo.UpdateRefCount(ctx, blob, previous)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return referenced, nil
+}
+
+// sweepDir removes every file of dir older than threshold, which isn't kept
+func (s *Sweeper) sweepDir(dir string, threshold time.Time, keep func(name string) bool,
+	report *Report) error {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() || file.ModTime().After(threshold) || keep(file.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		report.OrphanedFiles = append(report.OrphanedFiles, path)
+		report.FreedBytes += file.Size()
+		if s.DryRun {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sweeper) mediaPath(fType int) string {
+	switch fType {
+	case model.PostTypeImage:
+		return s.config.ImagePath
+	case model.PostTypeAudio:
+		return s.config.AudioPath
+	}
+	return s.config.VideoPath
+}
+
+// assetDir resolves a configured asset path like /assets/images/ to an
+// absolute directory. Empty paths and paths outside of the assets root are
+// refused, a missing config key must never turn the working directory into
+// a sweep target.
+func assetDir(path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", errors.New("sweeper: asset directory is not configured")
+	}
+	root, err := filepath.Abs(assetsRoot)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.Abs("." + path)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		return "", errors.New("sweeper: asset directory " + path + " is outside of " + root)
+	}
+	return dir, nil
+}
+
+func blobKey(fType int, path string) string {
+	return strconv.Itoa(fType) + ":" + path
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for webhooks pointing at the server itself
+// or at the network it runs in, unless their host is allowed explicitly
+var ErrForbiddenAddress = errors.New("webhook: address is not allowed")
+
+// The networks webhooks must not reach, besides loopback, link-local,
+// multicast and unspecified addresses
+var privateNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// forbidden reports whether webhooks must not connect to the address
+func forbidden(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// guardedClient returns a client refusing to connect to forbidden addresses.
+// The address is checked when dialing, after the name was resolved, so a
+// host can't pass a check and resolve to an internal address later on.
+// Redirects are not followed and proxies are not used.
+func guardedClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || forbidden(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: noRedirects,
+	}
+}
+
+// trustedClient returns a client for the hosts allowed by the config
+func trustedClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     &http.Transport{DialContext: (&net.Dialer{Timeout: timeout}).DialContext},
+		CheckRedirect: noRedirects,
+	}
+}
+
+func noRedirects(req *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// CheckURL validates the url of a new webhook. Hosts resolving to forbidden
+// addresses are refused right away, deliveries are checked again when
+// connecting.
+func (d *Deliverer) CheckURL(ctx context.Context, raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return errors.New("webhook: url needs an http or https scheme and a host")
+	}
+	if d.allowed(target) {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, target.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if forbidden(addr.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// allowed reports whether the host of the url is exempt from the address
+// checks
+func (d *Deliverer) allowed(target *url.URL) bool {
+	return d.allowedHosts[strings.ToLower(target.Hostname())]
+}
+
+// clientFor returns the client delivering to the url
+func (d *Deliverer) clientFor(raw string) (*http.Client, error) {
+	target, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if d.allowed(target) {
+		return d.trusted, nil
+	}
+	return d.client, nil
+}
@@ -0,0 +1,318 @@
+// Package webhook passes the events of a group on to the URLs registered by
+// its admin. Deliveries are queued in the database, so they survive restarts
+// and failed deliveries are retried with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+// The headers sent with every delivery
+const (
+	HeaderEvent     = "X-Innoserver-Event"
+	HeaderDelivery  = "X-Innoserver-Delivery"
+	HeaderSignature = "X-Innoserver-Signature"
+)
+
+// Events lists the types of events webhooks can subscribe to, personal
+// events like mentions are not passed on
+var Events = []string{
+	model.EventPostCreated,
+	model.EventPostRemoved,
+	model.EventOptionsChanged,
+	model.EventCommentCreated,
+	model.EventMemberAdded,
+	model.EventVoteCast,
+	model.EventVotingClosed,
+	model.EventSessionStarted,
+}
+
+// The number of due deliveries attempted per tick
+const batchSize = 100
+
+// The longest error message stored with a delivery
+const maxErrorLength = 1024
+
+// The longest wait between two attempts of a delivery
+const maxBackoff = time.Hour
+
+// The time a delivery may take before it counts as failed
+const deliveryTimeout = 10 * time.Second
+
+type repository interface {
+	SelectByGroup(ctx context.Context, groupID int) ([]*model.Webhook, error)
+	AddDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	SelectDue(ctx context.Context, now time.Time, limit uint64) ([]*model.WebhookDelivery, error)
+}
+
+// Supported reports whether webhooks can subscribe to the type of events
+func Supported(kind string) bool {
+	for _, event := range Events {
+		if event == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribed reports whether the webhook wants to receive the type of events
+func Subscribed(webhook *model.Webhook, kind string) bool {
+	for _, event := range webhook.Events {
+		if event == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature of the body sent in the signature header, the
+// hex encoded HMAC-SHA256 of the body keyed with the secret of the webhook
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret generates a random key for signing the payloads of a webhook
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// Deliverer queues the events of groups for their webhooks and sends the
+// due deliveries. Events are queued in memory first, so publishing requests
+// are not held up by the database.
+type Deliverer struct {
+	repo         repository
+	client       *http.Client
+	trusted      *http.Client
+	allowedHosts map[string]bool
+	maxAttempts  int
+	backoff      time.Duration
+	events       chan *model.Event
+	log          *logrus.Entry
+}
+
+// NewDeliverer creates a deliverer giving up on deliveries after
+// maxAttempts failed attempts. Webhooks may only reach public addresses,
+// besides the allowed hosts.
+func NewDeliverer(repo repository, maxAttempts int, allowedHosts []string, log *logrus.Logger) *Deliverer {
+	allowed := make(map[string]bool, len(allowedHosts))
+	for _, host := range allowedHosts {
+		allowed[strings.ToLower(host)] = true
+	}
+	return &Deliverer{
+		repo:         repo,
+		client:       guardedClient(deliveryTimeout),
+		trusted:      trustedClient(deliveryTimeout),
+		allowedHosts: allowed,
+		maxAttempts:  maxAttempts,
+		backoff:      30 * time.Second,
+		events:       make(chan *model.Event, 256),
+		log:          log.WithField("component", "webhooks"),
+	}
+}
+
+// Enqueue queues the event, it is meant to be subscribed to the event bus.
+// Events are dropped if the queue is full.
+func (d *Deliverer) Enqueue(e *model.Event) {
+	if !e.GroupID.Valid || !Supported(e.Type) {
+		return
+	}
+	select {
+	case d.events <- e:
+	default:
+		d.log.WithField("type", e.Type).Warnln("webhook queue is full, dropping event")
+	}
+}
+
+// Collect stores the deliveries of the queued events until the context is
+// done
+func (d *Deliverer) Collect(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-d.events:
+			d.enqueue(ctx, e)
+		}
+	}
+}
+
+// enqueue stores a delivery of the event for every webhook of its group
+// subscribed to it
+func (d *Deliverer) enqueue(ctx context.Context, e *model.Event) {
+	webhooks, err := d.repo.SelectByGroup(ctx, int(e.GroupID.Int32))
+	if err != nil {
+		d.log.WithField("group_uid", e.GroupUid).WithError(err).Errorln("fetching webhooks failed")
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		d.log.WithField("type", e.Type).WithError(err).Errorln("encoding event failed")
+		return
+	}
+	for _, webhook := range webhooks {
+		if !Subscribed(webhook, e.Type) {
+			continue
+		}
+		delivery, err := NewDelivery(webhook, e.Type, payload)
+		if err == nil {
+			err = d.repo.AddDelivery(ctx, delivery)
+		}
+		if err != nil {
+			d.log.WithField("webhook", webhook.UniqueID).WithError(err).Errorln("queueing delivery failed")
+		}
+	}
+}
+
+// NewDelivery prepares a pending delivery of the payload to the webhook,
+// which is due immediately
+func NewDelivery(webhook *model.Webhook, kind string, payload []byte) (*model.WebhookDelivery, error) {
+	uid, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	return &model.WebhookDelivery{
+		UniqueID:    uid.String(),
+		WebhookID:   webhook.ID,
+		EventType:   kind,
+		Payload:     string(payload),
+		State:       model.DeliveryPending,
+		NextAttempt: time.Now(),
+		Url:         webhook.Url,
+		Secret:      webhook.Secret,
+	}, nil
+}
+
+// Run ticks periodically until the context is cancelled
+func (d *Deliverer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Tick(ctx); err != nil {
+				d.log.WithError(err).Errorln("delivering webhooks failed")
+			}
+		}
+	}
+}
+
+// Tick attempts every delivery which is due
+func (d *Deliverer) Tick(ctx context.Context) error {
+	deliveries, err := d.repo.SelectDue(ctx, time.Now(), batchSize)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		if err := d.Attempt(ctx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Attempt sends the delivery once and stores the outcome. Failed deliveries
+// are rescheduled with exponential backoff until they run out of attempts.
+// The returned error only reports failures storing the outcome.
+func (d *Deliverer) Attempt(ctx context.Context, delivery *model.WebhookDelivery) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatus, delivery.LastError = 0, ""
+	status, err := d.send(ctx, delivery)
+	delivery.LastStatus = status
+	switch {
+	case err == nil:
+		delivery.State = model.DeliveryDelivered
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.State = model.DeliveryFailed
+		delivery.LastError = truncate(err.Error())
+	default:
+		delivery.NextAttempt = now.Add(d.delay(delivery.Attempts))
+		delivery.LastError = truncate(err.Error())
+	}
+	d.log.WithFields(logrus.Fields{
+		"delivery": delivery.UniqueID,
+		"attempt":  delivery.Attempts,
+		"status":   status,
+		"state":    delivery.State,
+	}).Debugln("webhook delivery attempted")
+	return d.repo.UpdateDelivery(ctx, delivery)
+}
+
+// delay returns the wait before the next attempt after attempts failed ones
+func (d *Deliverer) delay(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// truncate shortens error messages to the size of their column
+func truncate(msg string) string {
+	if len(msg) > maxErrorLength {
+		return msg[:maxErrorLength]
+	}
+	return msg
+}
+
+// send posts the signed payload to the url of the webhook, every response
+// besides 2xx counts as a failure
+func (d *Deliverer) send(ctx context.Context, delivery *model.WebhookDelivery) (int, error) {
+	client, err := d.clientFor(delivery.Url)
+	if err != nil {
+		return 0, err
+	}
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.UniqueID)
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook: unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,326 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gitlab.com/innoserver/pkg/model"
+)
+
+type fakeRepository struct {
+	webhooks   []*model.Webhook
+	deliveries []*model.WebhookDelivery
+	updates    int
+}
+
+func (f *fakeRepository) SelectByGroup(ctx context.Context, groupID int) ([]*model.Webhook, error) {
+	webhooks := []*model.Webhook{}
+	for _, webhook := range f.webhooks {
+		if webhook.GroupID == groupID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (f *fakeRepository) AddDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	f.deliveries = append(f.deliveries, delivery)
+	return nil
+}
+
+func (f *fakeRepository) UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	f.updates++
+	return nil
+}
+
+func (f *fakeRepository) SelectDue(ctx context.Context, now time.Time, limit uint64) ([]*model.WebhookDelivery, error) {
+	due := []*model.WebhookDelivery{}
+	for _, delivery := range f.deliveries {
+		if delivery.State == model.DeliveryPending && !delivery.NextAttempt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	return due, nil
+}
+
+// receiver records the requests of a local webhook receiver answering
+// with status
+type receiver struct {
+	*httptest.Server
+	lock     sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	rec := &receiver{status: status}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		rec.lock.Lock()
+		defer rec.lock.Unlock()
+		rec.requests = append(rec.requests, r)
+		rec.bodies = append(rec.bodies, body)
+		w.WriteHeader(rec.status)
+	}))
+	return rec
+}
+
+func (rec *receiver) host(t *testing.T) string {
+	target, err := url.Parse(rec.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target.Hostname()
+}
+
+func newTestDeliverer(repo repository, maxAttempts int, allowedHosts ...string) *Deliverer {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	return NewDeliverer(repo, maxAttempts, allowedHosts, log)
+}
+
+func newTestDelivery(t *testing.T, rec *receiver) *model.WebhookDelivery {
+	webhook := &model.Webhook{ID: 1, Url: rec.URL, Secret: "secret"}
+	delivery, err := NewDelivery(webhook, model.EventPostCreated, []byte(`{"type":"post_created"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delivery
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"ping"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := Sign("secret", body); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+	if Sign("other", body) == want {
+		t.Error("signatures of different secrets are equal")
+	}
+}
+
+func TestAttemptDelivered(t *testing.T) {
+	rec := newReceiver(t, http.StatusNoContent)
+	defer rec.Close()
+	repo := &fakeRepository{}
+	d := newTestDeliverer(repo, 3, rec.host(t))
+	delivery := newTestDelivery(t, rec)
+
+	if err := d.Attempt(context.Background(), delivery); err != nil {
+		t.Fatal(err)
+	}
+	if delivery.State != model.DeliveryDelivered || delivery.DeliveredAt == nil {
+		t.Errorf("delivery not marked as delivered: %+v", delivery)
+	}
+	if delivery.LastStatus != http.StatusNoContent || delivery.LastError != "" || delivery.Attempts != 1 {
+		t.Errorf("unexpected outcome: %+v", delivery)
+	}
+	if repo.updates != 1 {
+		t.Errorf("outcome stored %d times, want 1", repo.updates)
+	}
+	if len(rec.requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(rec.requests))
+	}
+	req, body := rec.requests[0], rec.bodies[0]
+	if string(body) != delivery.Payload {
+		t.Errorf("body = %s, want %s", body, delivery.Payload)
+	}
+	if got := req.Header.Get(HeaderSignature); got != Sign("secret", body) {
+		t.Errorf("signature = %s, want %s", got, Sign("secret", body))
+	}
+	if got := req.Header.Get(HeaderEvent); got != model.EventPostCreated {
+		t.Errorf("event header = %s", got)
+	}
+	if got := req.Header.Get(HeaderDelivery); got != delivery.UniqueID {
+		t.Errorf("delivery header = %s, want %s", got, delivery.UniqueID)
+	}
+}
+
+func TestAttemptRetriesUntilFailed(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusNotFound, http.StatusFound} {
+		rec := newReceiver(t, status)
+		defer rec.Close()
+		repo := &fakeRepository{}
+		d := newTestDeliverer(repo, 3, rec.host(t))
+		delivery := newTestDelivery(t, rec)
+
+		for attempt := 1; attempt <= 3; attempt++ {
+			before := time.Now()
+			if err := d.Attempt(context.Background(), delivery); err != nil {
+				t.Fatal(err)
+			}
+			if delivery.Attempts != attempt || delivery.LastStatus != status || delivery.LastError == "" {
+				t.Fatalf("status %d, attempt %d: unexpected outcome %+v", status, attempt, delivery)
+			}
+			if attempt < 3 {
+				if delivery.State != model.DeliveryPending {
+					t.Fatalf("status %d, attempt %d: state = %d, want pending", status, attempt, delivery.State)
+				}
+				if next := before.Add(d.delay(attempt)); delivery.NextAttempt.Before(next) {
+					t.Errorf("status %d, attempt %d: next attempt %v before %v", status, attempt,
+						delivery.NextAttempt, next)
+				}
+			}
+		}
+		if delivery.State != model.DeliveryFailed || delivery.DeliveredAt != nil {
+			t.Errorf("status %d: delivery not failed after max attempts: %+v", status, delivery)
+		}
+		if len(rec.requests) != 3 {
+			t.Errorf("status %d: receiver got %d requests, want 3", status, len(rec.requests))
+		}
+	}
+}
+
+func TestTickDeliversDueDeliveries(t *testing.T) {
+	rec := newReceiver(t, http.StatusOK)
+	defer rec.Close()
+	repo := &fakeRepository{}
+	d := newTestDeliverer(repo, 3, rec.host(t))
+	due, later := newTestDelivery(t, rec), newTestDelivery(t, rec)
+	later.NextAttempt = time.Now().Add(time.Hour)
+	repo.deliveries = []*model.WebhookDelivery{due, later}
+
+	if err := d.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if due.State != model.DeliveryDelivered {
+		t.Errorf("due delivery state = %d, want delivered", due.State)
+	}
+	if later.State != model.DeliveryPending || later.Attempts != 0 {
+		t.Errorf("delivery which isn't due was attempted: %+v", later)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	d := newTestDeliverer(&fakeRepository{}, 8)
+	want := []time.Duration{
+		30 * time.Second,
+		time.Minute,
+		2 * time.Minute,
+		4 * time.Minute,
+		8 * time.Minute,
+		16 * time.Minute,
+		32 * time.Minute,
+		time.Hour,
+		time.Hour,
+	}
+	for i, delay := range want {
+		if got := d.delay(i + 1); got != delay {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, delay)
+		}
+	}
+	if got := d.delay(100); got != maxBackoff {
+		t.Errorf("delay(100) = %v, want %v", got, maxBackoff)
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	repo := &fakeRepository{webhooks: []*model.Webhook{
+		{ID: 1, GroupID: 1, Events: []string{model.EventPostCreated}},
+		{ID: 2, GroupID: 1, Events: []string{model.EventCommentCreated}},
+		{ID: 3, GroupID: 2, Events: []string{model.EventPostCreated}},
+	}}
+	d := newTestDeliverer(repo, 3)
+	group := sql.NullInt32{Int32: 1, Valid: true}
+
+	d.Enqueue(&model.Event{Type: model.EventPostCreated})
+	d.Enqueue(&model.Event{Type: model.EventMention, GroupID: group})
+	d.Enqueue(&model.Event{Type: model.EventPostCreated, GroupID: group, PostUid: "post"})
+	if len(d.events) != 1 {
+		t.Fatalf("queued %d events, want 1", len(d.events))
+	}
+	d.enqueue(context.Background(), <-d.events)
+
+	if len(repo.deliveries) != 1 {
+		t.Fatalf("queued %d deliveries, want 1", len(repo.deliveries))
+	}
+	delivery := repo.deliveries[0]
+	if delivery.WebhookID != 1 || delivery.State != model.DeliveryPending ||
+		delivery.EventType != model.EventPostCreated {
+		t.Errorf("unexpected delivery %+v", delivery)
+	}
+	if !strings.Contains(delivery.Payload, `"post_uid":"post"`) {
+		t.Errorf("payload misses the event: %s", delivery.Payload)
+	}
+}
+
+func TestInternalAddressesRefused(t *testing.T) {
+	rec := newReceiver(t, http.StatusOK)
+	defer rec.Close()
+	d := newTestDeliverer(&fakeRepository{}, 1)
+	delivery := newTestDelivery(t, rec)
+
+	if err := d.Attempt(context.Background(), delivery); err != nil {
+		t.Fatal(err)
+	}
+	if delivery.State != model.DeliveryFailed || !strings.Contains(delivery.LastError, ErrForbiddenAddress.Error()) {
+		t.Errorf("delivery to loopback not refused: %+v", delivery)
+	}
+	if len(rec.requests) != 0 {
+		t.Errorf("receiver got %d requests, want none", len(rec.requests))
+	}
+	if err := d.CheckURL(context.Background(), rec.URL); err != ErrForbiddenAddress {
+		t.Errorf("CheckURL(%s) = %v, want %v", rec.URL, err, ErrForbiddenAddress)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	d := newTestDeliverer(&fakeRepository{}, 1, "hooks.internal")
+	for raw, valid := range map[string]bool{
+		"http://hooks.internal/events":  true,
+		"https://HOOKS.internal:8443/x": true,
+		"http://127.0.0.1:8080/":        false,
+		"http://[::1]/":                 false,
+		"http://169.254.169.254/latest": false,
+		"http://10.1.2.3/":              false,
+		"ftp://hooks.internal/":         false,
+		"http:///path":                  false,
+	} {
+		if err := d.CheckURL(context.Background(), raw); (err == nil) != valid {
+			t.Errorf("CheckURL(%s) = %v, valid %v", raw, err, valid)
+		}
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1":       true,
+		"::1":             true,
+		"169.254.169.254": true,
+		"fe80::1":         true,
+		"10.0.0.1":        true,
+		"172.16.5.4":      true,
+		"172.32.0.1":      false,
+		"192.168.1.1":     true,
+		"100.64.0.1":      true,
+		"fd00::1":         true,
+		"0.0.0.0":         true,
+		"224.0.0.1":       true,
+		"93.184.216.34":   false,
+		"2606:4700::1111": false,
+	} {
+		if got := forbidden(net.ParseIP(addr)); got != want {
+			t.Errorf("forbidden(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
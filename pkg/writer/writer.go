@@ -1,6 +1,9 @@
 package writer
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
 )
 
@@ -32,3 +35,19 @@ func (s *extRespWriter) Header() http.Header {
 func (s *extRespWriter) Write(data []byte) (int, error) {
 	return s.w.Write(data)
 }
+
+// Hijack hands the connection over to the caller, as needed for websockets
+func (s *extRespWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer doesn't support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// Flush sends buffered data to the client, as needed for streamed responses
+func (s *extRespWriter) Flush() {
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}